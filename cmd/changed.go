@@ -1,28 +1,54 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 
-	"github.com/dorfire/heavenly/pkg/gitutil"
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/samber/lo"
 	cli "github.com/urfave/cli/v2"
+
+	"github.com/dorfire/heavenly/pkg/earthfile"
+	"github.com/dorfire/heavenly/pkg/gitutil"
+	"github.com/dorfire/heavenly/pkg/repopath"
 )
 
 func failIfTargetUnchanged(ctx *cli.Context) error {
 	targetPath := ctx.Args().First()
 
+	root, err := repoRoot()
+	if err != nil {
+		return err
+	}
+
 	targetDir, _, _ := strings.Cut(targetPath, "+")
-	diff, err := gitDiff(ctx, targetDir)
+	changes, err := detectRepoChanges(ctx, root, targetDir)
 	if err != nil {
 		return err
 	}
 
-	changed, err := targetInputsChanged(ctx, diff, targetPath)
+	tc, err := detectTargetChanges(root, changes, targetPath)
 	if err != nil {
 		return err
 	}
 
-	if !changed {
+	if ctx.Bool("json") {
+		jsonBytes, err := json.Marshal(struct {
+			Target         string            `json:"target"`
+			Changed        bool              `json:"changed"`
+			ChangedInputs  []string          `json:"changedInputs"`
+			ChangedRecipes []string          `json:"changedRecipes,omitempty"`
+			Diff           gitutil.ChangeSet `json:"diff"`
+		}{targetPath, tc.any(), repopath.Strings(tc.inputs.ToSlice()), tc.recipes, changes.files})
+		if err != nil {
+			return err
+		}
+		logger.PrintBytes(jsonBytes)
+		return lo.Ternary(tc.any(), nil, cli.Exit("", 1))
+	}
+
+	if !tc.any() {
 		return cli.Exit(fmt.Errorf("Earthly target %s has no input changes", targetPath), 1)
 	}
 
@@ -30,18 +56,49 @@ func failIfTargetUnchanged(ctx *cli.Context) error {
 	return nil
 }
 
-func targetInputsChanged(_ *cli.Context, repoChanges gitutil.ChangeSet, targetPath string) (bool, error) {
-	targetInputs, err := analyzeTargetDeps(targetPath)
+// targetChanges is what changed in the dependencies of a target.
+type targetChanges struct {
+	inputs  mapset.Set[repopath.Path] // Changed input files
+	recipes []string                  // Keys of the target and its deps whose recipes changed, in semantic mode
+}
+
+func (c targetChanges) any() bool {
+	return c.inputs.Cardinality() > 0 || len(c.recipes) > 0
+}
+
+func targetInputsChanged(root repopath.Root, changes repoChanges, targetPath string) (bool, error) {
+	tc, err := detectTargetChanges(root, changes, targetPath)
+	return tc.any(), err
+}
+
+// detectTargetChanges returns the input files of the given target which the given changes touch, and the recipes it
+// depends on that they changed.
+func detectTargetChanges(root repopath.Root, changes repoChanges, targetPath string) (targetChanges, error) {
+	ef, target, err := earthfile.ParseTarget(targetPath)
+	if err != nil {
+		return targetChanges{}, err
+	}
+	targetInputs, err := targetInputs(root, ef, target)
 	if err != nil {
-		return false, err
+		return targetChanges{}, err
 	}
 
-	logger.DebugPrintf("+ Added files: %v", repoChanges.Added)
-	logger.DebugPrintf("/ Modified files: %v", repoChanges.Modified)
-	logger.DebugPrintf("- Deleted files: %v", repoChanges.Deleted)
+	diff := changes.files
+	logger.DebugPrintf("+ Added files: %v", diff.Added)
+	logger.DebugPrintf("/ Modified files: %v", diff.Modified)
+	logger.DebugPrintf("- Deleted files: %v", diff.Deleted)
+	logger.DebugPrintf("> Renamed files: %v", diff.Renamed)
+	logger.DebugPrintf("= Copied files: %v", diff.Copied)
 
-	intersection := targetInputs.Intersect(repoChanges.All())
-	logger.DebugPrintf("🔥 Changed inputs: %v", intersection)
+	res := targetChanges{inputs: targetInputs.Intersect(changes.changedFiles())}
+	logger.DebugPrintf("🔥 Changed inputs: %v", res.inputs)
 
-	return intersection.Cardinality() > 0, nil
+	if changes.recipes != nil {
+		res.recipes, err = targetRecipeChanges(root, changes.recipes, ef, target, mapset.NewThreadUnsafeSet[string]())
+		if err != nil {
+			return targetChanges{}, err
+		}
+		logger.DebugPrintf("🔥 Changed recipes: %v", res.recipes)
+	}
+	return res, nil
 }
@@ -1,43 +1,50 @@
 package main
 
 import (
+	"fmt"
 	"os"
-	"strings"
 
+	"github.com/dorfire/heavenly/pkg/config"
 	"github.com/dorfire/heavenly/pkg/gitutil"
-	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/dorfire/heavenly/pkg/repopath"
 	"github.com/samber/lo"
 	"github.com/urfave/cli/v2"
 )
 
 func gitDiff(ctx *cli.Context, pathInRepo string) (gitutil.ChangeSet, error) {
+	opts := gitutil.DiffOptions{MergeBase: ctx.Bool("merge-base")}
 	fromRef := flagOrEnv(ctx, "from-ref", "GITHUB_BASE_REF")
 	toCommit := flagOrEnv(ctx, "to-commit", "GITHUB_SHA")
 
-	repo, err := gitutil.OpenRepo(pathInRepo)
-	if err != nil {
-		return gitutil.ChangeSet{}, err
+	switch {
+	case ctx.Bool("worktree") && ctx.Bool("staged"):
+		return gitutil.ChangeSet{}, fmt.Errorf("--worktree and --staged are mutually exclusive")
+	case ctx.Bool("worktree"), ctx.Bool("staged"):
+		opts.Target = lo.Ternary(ctx.Bool("staged"), gitutil.DiffStaged, gitutil.DiffWorktree)
+		if ctx.String("to-commit") != "" {
+			return gitutil.ChangeSet{}, fmt.Errorf("--to-commit can't be combined with --worktree or --staged")
+		}
+		// Uncommitted changes are diffed on top of HEAD, by default against it
+		toCommit = "HEAD"
+		if fromRef == "" {
+			fromRef = "HEAD"
+		}
+	case toCommit == "":
+		toCommit = "HEAD"
 	}
 
-	repoRoot := lo.Must(repo.Worktree()).Filesystem.Root()
-	logger.DebugPrintf("Repo .git path: %s", repoRoot)
-
-	refs, err := repo.References()
+	repo, err := gitutil.OpenRepo(pathInRepo)
 	if err != nil {
 		return gitutil.ChangeSet{}, err
 	}
 
-	err = refs.ForEach(func(r *plumbing.Reference) error {
-		if strings.Contains(r.Name().String(), fromRef) {
-			logger.DebugPrintf("Possible base ref match: %s", r.Name())
-		}
-		return nil
-	})
+	repoRoot, err := gitutil.RootDir(repo)
 	if err != nil {
 		return gitutil.ChangeSet{}, err
 	}
+	logger.DebugPrintf("Repo .git path: %s", repoRoot)
 
-	return gitutil.FilesChanged(ctx.Context, repo, fromRef, toCommit)
+	return gitutil.FilesChanged(ctx.Context, repo, fromRef, toCommit, opts)
 }
 
 func flagOrEnv(ctx *cli.Context, flagName, envVarName string) string {
@@ -47,3 +54,33 @@ func flagOrEnv(ctx *cli.Context, flagName, envVarName string) string {
 	}
 	return res
 }
+
+// loadConfig loads the heavenly config of the repo containing the current directory.
+func loadConfig() (*config.Config, error) {
+	root, err := repoRootDir()
+	if err != nil {
+		return nil, err
+	}
+	logger.DebugPrintf("Loading config from %s", root)
+	return config.Load(root)
+}
+
+// repoRoot returns the root of the git repo containing the current directory, which all analyzed paths are relative to.
+func repoRoot() (repopath.Root, error) {
+	dir, err := repoRootDir()
+	if err != nil {
+		return repopath.Root{}, err
+	}
+	return repopath.NewRoot(dir)
+}
+
+// repoRootDir returns the root of the git repo containing the current directory.
+// Outside of a git repo, the current directory is considered the root.
+func repoRootDir() (string, error) {
+	repo, err := gitutil.OpenRepo(".")
+	if err != nil {
+		logger.DebugPrintf("Not in a git repo (%v); using the current directory as the repo root", err)
+		return ".", nil
+	}
+	return gitutil.RootDir(repo)
+}
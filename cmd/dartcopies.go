@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/dorfire/heavenly/pkg/dartdepresolver"
+	"github.com/dorfire/heavenly/pkg/earthfilefmt"
+)
+
+func printCopyCommandsForDartDeps(cCtx *cli.Context) error {
+	pkgPath := cCtx.Args().First()
+	if pkgPath == "" {
+		return errors.New("missing Dart package argument")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	f := earthfilefmt.New(cfg.Format)
+
+	r, err := dartdepresolver.New(pkgPath, logger)
+	if err != nil {
+		return err
+	}
+
+	includeTransitive := cCtx.Bool("include-transitive")
+	copies, devCopies, err := r.ResolveImportsToCopyCommands(pkgPath, includeTransitive)
+	if err != nil {
+		return err
+	}
+
+	docArg := " "
+	if includeTransitive {
+		docArg = " --include-transitive "
+	}
+	docCmd := fmt.Sprintf("heavenly dartcopies%s%s", docArg, pkgPath)
+
+	fmt.Printf("\n%s# Dart path dependencies (generated with `%s`)\n", f.Indent(1), docCmd)
+	fmt.Println(formatCopyCommands(f, copies))
+
+	fmt.Printf("\n%s# Dart dev path dependencies (generated with `%s`)\n", f.Indent(1), docCmd)
+	fmt.Println(formatCopyCommands(f, devCopies))
+	fmt.Println()
+
+	return nil
+}
@@ -11,6 +11,14 @@ import (
 
 // TODO: support '#' comments
 func formatEarthfile(ctx *cli.Context) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if ctx.IsSet("normalize-args") {
+		cfg.Format.NormalizeArgs = ctx.Bool("normalize-args")
+	}
+
 	ef, err := earthfile.Parse(ctx.Args().First())
 	if err != nil {
 		return err
@@ -22,7 +30,7 @@ func formatEarthfile(ctx *cli.Context) error {
 		return err
 	}
 
-	formatted := earthfilefmt.Format(ef.Spec)
+	formatted := earthfilefmt.New(cfg.Format).Format(ef.Spec, orig)
 
 	logger.Printf(cmp.Diff(formatted, string(orig)))
 
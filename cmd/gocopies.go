@@ -3,7 +3,6 @@ package main
 import (
 	"errors"
 	"fmt"
-	"sort"
 	"strings"
 
 	"github.com/earthly/earthly/ast/spec"
@@ -14,16 +13,18 @@ import (
 	"github.com/dorfire/heavenly/pkg/godepresolver"
 )
 
-const (
-	selfCopyCmd = "COPY --dir +src/* ."
-)
-
 func printCopyCommandsForGoDeps(cCtx *cli.Context) error {
 	pkgPath := cCtx.Args().First()
 	if pkgPath == "" {
 		return errors.New("missing Go package argument")
 	}
 
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	f := earthfilefmt.New(cfg.Format)
+
 	r, err := godepresolver.New(cCtx.String("go-mod-dir"), logger)
 	if err != nil {
 		return err
@@ -41,30 +42,21 @@ func printCopyCommandsForGoDeps(cCtx *cli.Context) error {
 	}
 	docCmd := fmt.Sprintf("heavenly gocopies%s%s", docArg, pkgPath)
 
-	fmt.Printf("\n# Go imports (generated with `%s`)\n", docCmd)
-	fmt.Println(formatCopyCommands(copies))
+	fmt.Printf("\n%s# Go imports (generated with `%s`)\n", f.Indent(1), docCmd)
+	fmt.Println(formatCopyCommands(f, copies))
 
-	fmt.Printf("\n# Go test imports (generated with `%s`)\n", docCmd)
-	fmt.Println(formatCopyCommands(testCopies))
+	fmt.Printf("\n%s# Go test imports (generated with `%s`)\n", f.Indent(1), docCmd)
+	fmt.Println(formatCopyCommands(f, testCopies))
 	fmt.Println()
 
 	return nil
 }
 
-func formatCopyCommands(cmds []spec.Command) string {
-	cmdLines := lo.Map(cmds, func(c spec.Command, _ int) string { return earthfilefmt.FormatCmd(c.Name, c.Args) })
-	sort.Strings(cmdLines)
-
-	// "COPY --dir +src/* ." should be first
-	selfCopyIdx := sort.SearchStrings(cmdLines, selfCopyCmd)
-	if selfCopyIdx != 0 && selfCopyIdx < len(cmdLines) && cmdLines[selfCopyIdx] == selfCopyCmd {
-		var tail []string
-		if selfCopyIdx+1 < len(cmdLines) {
-			tail = cmdLines[selfCopyIdx+1:]
-		}
-		cmdLines = append(cmdLines[:selfCopyIdx], tail...)
-		cmdLines = append([]string{selfCopyCmd}, cmdLines...)
-	}
+// formatCopyCommands formats the given COPY commands as lines in a target recipe, using the given formatter, so that
+// they can be pasted into one as is.
+func formatCopyCommands(f *earthfilefmt.Formatter, cmds []spec.Command) string {
+	cmdLines := lo.Map(cmds, func(c spec.Command, _ int) string { return f.FormatCmd(c.Name, c.Args) })
+	earthfilefmt.SortCopyLines(cmdLines)
 
-	return strings.Join(cmdLines, "\n")
+	return strings.Join(lo.Map(cmdLines, func(l string, _ int) string { return f.Indent(1) + l }), "\n")
 }
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/earthly/earthly/ast/spec"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dorfire/heavenly/pkg/earthfilefmt"
+)
+
+func TestFormatCopyCommands(t *testing.T) {
+	cmds := []spec.Command{
+		{Name: "COPY", Args: []string{"--dir", "$TOP/libs/b/+src/*", "$TOP/libs/b/"}},
+		{Name: "COPY", Args: []string{"--dir", "+src/*", "."}},
+		{Name: "COPY", Args: []string{"--dir", "$TOP/libs/a/+src/*", "$TOP/libs/a/"}},
+	}
+
+	assert.Equal(t, "    COPY --dir +src/* .\n"+
+		"    COPY --dir $TOP/libs/a/+src/* $TOP/libs/a/\n"+
+		"    COPY --dir $TOP/libs/b/+src/* $TOP/libs/b/",
+		formatCopyCommands(earthfilefmt.New(earthfilefmt.DefaultOptions()), cmds))
+
+	opts := earthfilefmt.DefaultOptions()
+	opts.UseTabs = true
+	assert.Equal(t, "\tCOPY --dir +src/* .\n"+
+		"\tCOPY --dir $TOP/libs/a/+src/* $TOP/libs/a/\n"+
+		"\tCOPY --dir $TOP/libs/b/+src/* $TOP/libs/b/",
+		formatCopyCommands(earthfilefmt.New(opts), cmds))
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/earthly/earthly/ast/spec"
+	"github.com/samber/lo"
+	cli "github.com/urfave/cli/v2"
+	"golang.org/x/exp/maps"
+
+	"github.com/dorfire/heavenly/pkg/earthfile"
+	"github.com/dorfire/heavenly/pkg/repopath"
+)
+
+// outputTargetHashes outputs a JSON map from each target in the repo to its fingerprint.
+func outputTargetHashes(ctx *cli.Context) error {
+	root, err := repoRoot()
+	if err != nil {
+		return err
+	}
+
+	hashes, err := targetHashes(root)
+	if err != nil {
+		return err
+	}
+
+	jsonBytes, err := json.Marshal(hashes)
+	if err != nil {
+		return err
+	}
+	if out := ctx.String("output"); out != "" {
+		if err = os.WriteFile(out, jsonBytes, 0644); err != nil {
+			return err
+		}
+		logger.Printf("🌍 Wrote the fingerprints of %d targets to %s\n", len(hashes), out)
+		return nil
+	}
+	logger.PrintBytes(jsonBytes)
+	return nil
+}
+
+// targetHashes returns the fingerprint of each target in the repo, by target key.
+func targetHashes(root repopath.Root) (map[string]string, error) {
+	paths, err := earthfile.Discover(root.Dir())
+	if err != nil {
+		return nil, err
+	}
+
+	fp := newFingerprinter(root)
+	res := map[string]string{}
+	for _, p := range paths {
+		ef, err := earthfile.Parse(p)
+		if err != nil {
+			return nil, err
+		}
+		for i := range ef.Spec.Targets {
+			t := &ef.Spec.Targets[i]
+			key, err := targetKey(root, ef, t.Name)
+			if err != nil {
+				return nil, err
+			}
+			if res[key], err = fp.target(ef, t); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return res, nil
+}
+
+// outputImpactedTargets outputs the targets whose fingerprints differ between two outputs of outputTargetHashes,
+// including ones that were added.
+func outputImpactedTargets(ctx *cli.Context) error {
+	from, err := readTargetHashes(ctx.String("from"))
+	if err != nil {
+		return err
+	}
+	to, err := readTargetHashes(ctx.String("to"))
+	if err != nil {
+		return err
+	}
+
+	impacted := impactedTargets(from, to)
+	if !ctx.Bool("json") {
+		logger.Printf(strings.Join(impacted, "\n"))
+		return nil
+	}
+	jsonBytes, err := json.Marshal(impacted)
+	if err != nil {
+		return err
+	}
+	logger.PrintBytes(jsonBytes)
+	return nil
+}
+
+// impactedTargets returns the keys of the targets in to whose fingerprints differ from the ones in from, sorted.
+func impactedTargets(from, to map[string]string) []string {
+	res := lo.Filter(maps.Keys(to), func(key string, _ int) bool { return from[key] != to[key] })
+	sort.Strings(res)
+	return res
+}
+
+func readTargetHashes(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var res map[string]string
+	if err = json.Unmarshal(b, &res); err != nil {
+		return nil, fmt.Errorf("could not parse target hashes in %s: %w", path, err)
+	}
+	return res, nil
+}
+
+// targetKey returns the canonical reference to a target, relative to the repo root; e.g. `./dir+target`, or `+target`
+// for targets in the root Earthfile.
+func targetKey(root repopath.Root, ef *earthfile.Earthfile, name string) (string, error) {
+	dir, err := root.Rel(ef.Dir)
+	if err != nil {
+		return "", err
+	}
+	return targetKeyIn(dir, name), nil
+}
+
+// targetKeyIn returns the canonical reference to a target in the Earthfile in the given dir.
+func targetKeyIn(dir repopath.Path, name string) string {
+	if dir == "." {
+		return "+" + name
+	}
+	return "./" + string(dir) + "+" + name
+}
+
+// fingerprinter computes target fingerprints: hashes of a target's recipe, the user-defined commands it calls, its
+// Earthfile's VERSION, IMPORT and global ARGs, the contents of its inputs, and the fingerprints of the targets it
+// references.
+type fingerprinter struct {
+	root     repopath.Root
+	targets  map[string]string        // Fingerprints by target key
+	visiting mapset.Set[string]       // Keys of the targets being fingerprinted, to detect cycles
+	files    map[repopath.Path]string // Content hashes
+}
+
+func newFingerprinter(root repopath.Root) *fingerprinter {
+	return &fingerprinter{
+		root:     root,
+		targets:  map[string]string{},
+		visiting: mapset.NewThreadUnsafeSet[string](),
+		files:    map[repopath.Path]string{},
+	}
+}
+
+func (f *fingerprinter) target(ef *earthfile.Earthfile, t *spec.Target) (string, error) {
+	key, err := targetKey(f.root, ef, t.Name)
+	if err != nil {
+		return "", err
+	}
+	if res, ok := f.targets[key]; ok {
+		return res, nil
+	}
+	if !f.visiting.Add(key) {
+		return "", fmt.Errorf("target reference cycle through %s", key)
+	}
+	defer f.visiting.Remove(key)
+
+	commands, err := earthfile.CalledCommandsText(ef, t.Recipe)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", key, err)
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "target %s\n%s", key, earthfile.RecipeText(t.Recipe))
+	fmt.Fprintf(h, "header\n%scommands\n%s", earthfile.HeaderText(ef), commands)
+
+	globals := maps.Keys(ef.Globals)
+	sort.Strings(globals)
+	for _, name := range globals {
+		fmt.Fprintf(h, "arg %s=%s\n", name, ef.Globals[name])
+	}
+
+	inputs, err := targetInputs(f.root, ef, t)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", key, err)
+	}
+	for _, p := range repopath.Strings(inputs.ToSlice()) {
+		contentHash, err := f.file(repopath.Path(p))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "input %s %s\n", p, contentHash)
+	}
+
+	refs, err := earthfile.CollectTargetRefs(ef, t)
+	if err != nil {
+		return "", err
+	}
+	deps := map[string]string{}
+	for _, ref := range refs {
+		refEf, refT, err := ef.Target(ref.Target)
+		if err != nil {
+			return "", fmt.Errorf("%s: could not find target %s: %w", key, ref.Target, err)
+		}
+		refKey, err := targetKey(f.root, refEf, refT.Name)
+		if err != nil {
+			return "", err
+		}
+		if deps[refKey], err = f.target(refEf, refT); err != nil {
+			return "", err
+		}
+	}
+	depKeys := maps.Keys(deps)
+	sort.Strings(depKeys)
+	for _, k := range depKeys {
+		fmt.Fprintf(h, "dep %s %s\n", k, deps[k])
+	}
+
+	res := hex.EncodeToString(h.Sum(nil))
+	f.targets[key] = res
+	return res, nil
+}
+
+// file returns the content hash of the given file, or a placeholder if it doesn't exist.
+func (f *fingerprinter) file(p repopath.Path) (string, error) {
+	if res, ok := f.files[p]; ok {
+		return res, nil
+	}
+
+	res, err := hashFile(f.root.Abs(p))
+	if err != nil {
+		return "", err
+	}
+	f.files[p] = res
+	return res, nil
+}
+
+func hashFile(path string) (string, error) {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "missing", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "symlink %s", target)
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
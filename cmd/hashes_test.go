@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/maps"
+
+	"github.com/dorfire/heavenly/pkg/repopath"
+)
+
+var hashesTestFiles = map[string]string{
+	"Earthfile": "VERSION 0.6\nFROM alpine\n\nall:\n    BUILD ./app+build\n",
+	"app/Earthfile": "VERSION 0.6\nFROM alpine\n\n" +
+		"build:\n    FROM ../lib+src\n    COPY main.txt .\n    RUN cat main.txt\n\n" +
+		"other:\n    RUN true\n",
+	"app/main.txt": "main",
+	"lib/Earthfile": "VERSION 0.6\nFROM alpine\n\nsrc:\n    COPY l.txt .\n    DO +SETUP\n\n" +
+		"SETUP:\n    COMMAND\n    RUN echo setup\n",
+	"lib/l.txt": "l",
+}
+
+// hashesOf fingerprints a repo with the given files, along with the ones in hashesTestFiles.
+func hashesOf(t *testing.T, files map[string]string) (map[string]string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, hashesTestFiles)
+	writeFiles(t, dir, files)
+	root, err := repopath.NewRoot(dir)
+	require.NoError(t, err)
+	return targetHashes(root)
+}
+
+func TestTargetHashes(t *testing.T) {
+	base, err := hashesOf(t, nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"+all", "./app+build", "./app+other", "./lib+src"}, maps.Keys(base))
+
+	// The checkout dir doesn't matter
+	again, err := hashesOf(t, nil)
+	require.NoError(t, err)
+	assert.Equal(t, base, again)
+
+	for _, tc := range []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{"input content", map[string]string{"app/main.txt": "main2"}, []string{"+all", "./app+build"}},
+		{"dependency input content", map[string]string{"lib/l.txt": "l2"}, []string{"+all", "./app+build", "./lib+src"}},
+		// +other has no inputs, so only its recipe changed; +build has its Earthfile as an input
+		{"recipe", map[string]string{
+			"app/Earthfile": hashesTestFiles["app/Earthfile"] + "    RUN false\n",
+		}, []string{"+all", "./app+build", "./app+other"}},
+		{"dependency recipe", map[string]string{
+			"lib/Earthfile": strings.Replace(hashesTestFiles["lib/Earthfile"], "COPY l.txt .", "COPY l.txt ./l/", 1),
+		}, []string{"+all", "./app+build", "./lib+src"}},
+		{"user-defined command", map[string]string{
+			"lib/Earthfile": strings.Replace(hashesTestFiles["lib/Earthfile"], "echo setup", "echo setup2", 1),
+		}, []string{"+all", "./app+build", "./lib+src"}},
+		{"version", map[string]string{
+			"app/Earthfile": strings.Replace(hashesTestFiles["app/Earthfile"], "VERSION 0.6", "VERSION 0.5", 1),
+		}, []string{"+all", "./app+build", "./app+other"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			changed, err := hashesOf(t, tc.files)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, impactedTargets(base, changed))
+		})
+	}
+}
+
+func TestTargetHashesCycle(t *testing.T) {
+	_, err := hashesOf(t, map[string]string{
+		"cycle/Earthfile": "VERSION 0.6\nFROM alpine\n\na:\n    BUILD +b\n\nb:\n    BUILD +a\n",
+	})
+	assert.ErrorContains(t, err, "cycle")
+}
+
+func TestImpactedTargets(t *testing.T) {
+	from := map[string]string{"+a": "1", "+b": "2", "+removed": "3"}
+	to := map[string]string{"+a": "1", "+b": "changed", "+added": "4"}
+	assert.Equal(t, []string{"+added", "+b"}, impactedTargets(from, to))
+	assert.Equal(t, []string{}, impactedTargets(from, from))
+}
@@ -17,6 +17,7 @@ import (
 	cli "github.com/urfave/cli/v2"
 
 	"github.com/dorfire/heavenly/pkg/earthfile"
+	"github.com/dorfire/heavenly/pkg/repopath"
 )
 
 func inspectTargetInputs(ctx *cli.Context) error {
@@ -25,7 +26,12 @@ func inspectTargetInputs(ctx *cli.Context) error {
 		return errors.New("missing Earthly target argument")
 	}
 
-	targetInputs, err := analyzeTargetDeps(tPath)
+	root, err := repoRoot()
+	if err != nil {
+		return err
+	}
+
+	targetInputs, err := analyzeTargetDeps(root, tPath)
 	if err != nil {
 		return err
 	}
@@ -34,7 +40,7 @@ func inspectTargetInputs(ctx *cli.Context) error {
 	if ctx.Bool("pretty") {
 		displayTargetInputs = prettyPathTree(targetInputs)
 	} else {
-		displayTargetInputs = strings.Join(targetInputs.ToSlice(), "\n")
+		displayTargetInputs = strings.Join(repopath.Strings(targetInputs.ToSlice()), "\n")
 	}
 
 	logger.Printf(displayTargetInputs)
@@ -42,32 +48,45 @@ func inspectTargetInputs(ctx *cli.Context) error {
 }
 
 // analyzeTargetDeps analyzes an Earthly target at the given path and returns the files it is assumed to depend on.
-func analyzeTargetDeps(tPath string) (mapset.Set[string], error) {
+func analyzeTargetDeps(root repopath.Root, tPath string) (mapset.Set[repopath.Path], error) {
 	ef, target, err := earthfile.ParseTarget(tPath)
 	if err != nil {
 		return nil, err
 	}
+	return targetInputs(root, ef, target)
+}
 
-	copies := earthfile.CollectCopyCommands(ef, target)
+// targetInputs returns the files the given target is assumed to depend on.
+func targetInputs(root repopath.Root, ef *earthfile.Earthfile, target *spec.Target) (mapset.Set[repopath.Path], error) {
+	copies, err := earthfile.CollectCopyCommands(ef, target)
+	if err != nil {
+		return nil, err
+	}
 
 	logger.DebugPrintf("Inspecting Earthfile @ %s", ef.Dir)
 	debugPrintCopyCommands(target, copies)
 
-	var targetInputs []string
+	res := mapset.NewSet[repopath.Path]()
 	for _, cp := range copies {
 		files, err := expandCopyCmd(ef, cp)
 		if err != nil {
 			return nil, err
 		}
-		targetInputs = append(targetInputs, files...)
+		for _, f := range files {
+			p, err := root.Rel(f)
+			if err != nil {
+				return nil, fmt.Errorf("in %s: COPY source: %w", cp.File.Path, err)
+			}
+			res.Add(p)
+		}
 	}
-	return mapset.NewSet(targetInputs...), nil
+	return res, nil
 }
 
-func prettyPathTree(paths mapset.Set[string]) string {
+func prettyPathTree(paths mapset.Set[repopath.Path]) string {
 	res := new(bytes.Buffer)
 	t := asciitree.Tree{}
-	for _, p := range paths.ToSlice() {
+	for _, p := range repopath.Strings(paths.ToSlice()) {
 		t.Add(p)
 	}
 	t.Fprint(res, false, "")
@@ -84,8 +103,9 @@ func debugPrintCopyCommands(target *spec.Target, copies []earthfile.CopyCmd) {
 func expandCopyCmd(ef *earthfile.Earthfile, cp earthfile.CopyCmd) (res []string, err error) {
 	// Each 'COPY' command either references an Earthly target, a simple path, or a glob pattern.
 
-	if cp.Line == earthfile.SentinelCopyCmdLine { // Nothing to expand
-		return []string{cp.File.Path}, nil
+	// Nothing to expand; both the Earthfile with the FROM command and the FROM'd one define the target
+	if cp.Line == earthfile.SentinelCopyCmdLine {
+		return lo.Uniq([]string{cp.File.Path, cp.From}), nil
 	}
 
 	// First, replace $ARG refs with their underlying value
@@ -101,11 +121,18 @@ func expandCopyCmd(ef *earthfile.Earthfile, cp earthfile.CopyCmd) (res []string,
 			return nil, fmt.Errorf("in %s: could not find target '%s': %w", ef.Path, cp.From, err)
 		}
 
-		targetCPs := earthfile.CollectCopyCommands(fromEarthfile, fromTarget)
+		targetCPs, err := earthfile.CollectCopyCommands(fromEarthfile, fromTarget)
+		if err != nil {
+			return nil, err
+		}
 
-		res = lo.FlatMap(targetCPs, func(c earthfile.CopyCmd, _ int) []string {
-			return lo.Must(expandCopyCmd(c.File, c))
-		})
+		for _, c := range targetCPs {
+			files, err := expandCopyCmd(c.File, c)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, files...)
+		}
 
 		// TODO: better filtering logics
 		normalizedSelector := strings.Trim(targetSelector, "/")
@@ -130,6 +157,9 @@ func expandCopyCmd(ef *earthfile.Earthfile, cp earthfile.CopyCmd) (res []string,
 		}
 	} else {
 		res = []string{filepath.Join(cp.File.Dir, cp.From)}
+		if !fileutil.FileExistsBestEffort(res[0]) {
+			logger.DebugPrintf("[%s] COPY source %s does not exist; run `heavenly lint` for details", ef.Dir, res[0])
+		}
 	}
 
 	logger.DebugPrintf("[%s] Expanded `%s` to =>\n  %v", ef.Dir, cp.Line, res)
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dorfire/heavenly/pkg/gitutil"
+	"github.com/dorfire/heavenly/pkg/repopath"
+)
+
+func TestAnalyzeTargetDeps(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app/Earthfile": "VERSION 0.6\n\ntest:\n    FROM ../lib+src\n    COPY main.txt .\n",
+		"app/main.txt":  "main",
+		"lib/Earthfile": "VERSION 0.6\n\nsrc:\n    FROM alpine\n    COPY l.txt .\n",
+		"lib/l.txt":     "l",
+	})
+	root, err := repopath.NewRoot(dir)
+	require.NoError(t, err)
+
+	inputs, err := analyzeTargetDeps(root, filepath.Join(dir, "app")+"+test")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"app/Earthfile", "app/main.txt", "lib/Earthfile", "lib/l.txt"},
+		repopath.Strings(inputs.ToSlice()))
+}
+
+func TestTargetAnalysisFromSubdir(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	base := commitFiles(t, repo, map[string]string{
+		"app/Earthfile": "VERSION 0.6\n\ntest:\n    FROM ../lib+src\n    COPY main.txt .\n",
+		"app/main.txt":  "main",
+		"lib/Earthfile": "VERSION 0.6\n\nsrc:\n    FROM alpine\n    COPY l.txt .\n",
+		"lib/l.txt":     "l",
+	}, "base")
+	writeFiles(t, dir, map[string]string{"lib/l.txt": "l2", "other.txt": "other"})
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	// analyze returns the inputs and changed inputs of a target, as analyzed from the given dir
+	analyze := func(cwd, target string) ([]string, []string) {
+		require.NoError(t, os.Chdir(filepath.Join(dir, cwd)))
+		root, err := repoRoot()
+		require.NoError(t, err)
+
+		inputs, err := analyzeTargetDeps(root, target)
+		require.NoError(t, err)
+		diff, err := gitutil.FilesChanged(context.Background(), repo, base.String(), base.String(),
+			gitutil.DiffOptions{Target: gitutil.DiffWorktree})
+		require.NoError(t, err)
+		changes, err := detectTargetChanges(root, repoChanges{files: diff}, target)
+		require.NoError(t, err)
+		return repopath.Strings(inputs.ToSlice()), repopath.Strings(changes.inputs.ToSlice())
+	}
+
+	inputs, changed := analyze(".", "./app+test")
+	assert.ElementsMatch(t, []string{"app/Earthfile", "app/main.txt", "lib/Earthfile", "lib/l.txt"}, inputs)
+	assert.Equal(t, []string{"lib/l.txt"}, changed)
+
+	for cwd, target := range map[string]string{"app": "+test", "lib": "../app+test"} {
+		subInputs, subChanged := analyze(cwd, target)
+		assert.ElementsMatch(t, inputs, subInputs, cwd)
+		assert.Equal(t, changed, subChanged, cwd)
+	}
+}
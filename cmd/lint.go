@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	cli "github.com/urfave/cli/v2"
+
+	"github.com/dorfire/heavenly/pkg/lint"
+)
+
+func lintRepo(ctx *cli.Context) error {
+	format, err := lint.ParseFormat(ctx.String("format"))
+	if err != nil {
+		return err
+	}
+	// The diff would precede the machine-readable document on stdout
+	if ctx.Bool("diff") && format != lint.FormatText {
+		return fmt.Errorf("--diff can't be combined with --format %s", format)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	root, err := repoRootDir()
+	if err != nil {
+		return err
+	}
+
+	repo, err := lint.LoadRepo(root, logger)
+	if err != nil {
+		return err
+	}
+
+	diags, err := lint.Run(repo, cfg.Lint)
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool("fix") || ctx.Bool("diff") {
+		if diags, err = applyLintFixes(repo, cfg.Lint, diags, ctx.Bool("diff")); err != nil {
+			return err
+		}
+	}
+
+	if err = lint.WriteDiagnostics(os.Stdout, format, diags); err != nil {
+		return err
+	}
+
+	if lint.HasErrors(diags) {
+		return cli.Exit(fmt.Errorf("found %d lint issues in %d Earthfiles", len(diags), len(repo.Earthfiles)), 1)
+	}
+	// Machine-readable formats are kept free of other output
+	if format == lint.FormatText {
+		logger.Printf("🌍 %d lint issues found in %d Earthfiles\n", len(diags), len(repo.Earthfiles))
+	}
+	return nil
+}
+
+// applyLintFixes prints the diff of the suggested fixes of the given diagnostics if preview is set, or writes them to
+// the repo otherwise. In the latter case, it returns the diagnostics that remain after re-linting the fixed repo.
+func applyLintFixes(repo *lint.Repo, cfg lint.Config, diags []lint.Diagnostic, preview bool) ([]lint.Diagnostic, error) {
+	files, fixed, err := repo.ApplyFixes(diags)
+	if err != nil {
+		return nil, err
+	}
+
+	if preview {
+		for _, f := range files {
+			fmt.Print(lint.UnifiedDiff(f))
+		}
+		return diags, nil
+	}
+
+	if err = repo.WriteFixes(files); err != nil {
+		return nil, err
+	}
+	logger.Printf("🔧 fixed %d lint issues in %d Earthfiles\n", fixed, len(files))
+	if fixed == 0 {
+		return diags, nil
+	}
+
+	if repo, err = lint.LoadRepo(repo.Root, logger); err != nil {
+		return nil, err
+	}
+	return lint.Run(repo, cfg)
+}
+
+func lintUsageText() string {
+	b := new(strings.Builder)
+	b.WriteString("rules:\n")
+	for _, r := range lint.Rules() {
+		fmt.Fprintf(b, "- %s: %s\n", r.Name(), r.Doc())
+	}
+	return b.String()
+}
@@ -1,12 +1,13 @@
 package main
 
 import (
-	"fmt"
 	"log"
 	"os"
 
 	"github.com/earthly/earthly/conslogging"
 	cli "github.com/urfave/cli/v2"
+
+	"github.com/dorfire/heavenly/pkg/lint"
 )
 
 var (
@@ -14,6 +15,23 @@ var (
 	gitDiffArgs = []cli.Flag{
 		&cli.StringFlag{Name: "from-ref"},
 		&cli.StringFlag{Name: "to-commit"},
+		&cli.BoolFlag{
+			Name:  "merge-base",
+			Usage: "diff against the merge base of from-ref and to-commit, like git diff A...B",
+		},
+		&cli.BoolFlag{
+			Name:  "worktree",
+			Usage: "diff the working tree, including uncommitted and untracked files, against from-ref (default HEAD)",
+		},
+		&cli.BoolFlag{
+			Name:  "staged",
+			Usage: "diff the staged changes in the index against from-ref (default HEAD)",
+		},
+		&cli.BoolFlag{
+			Name: "semantic",
+			Usage: "compare the recipes of targets in changed Earthfiles, rather than consider all targets that " +
+				"depend on them changed",
+		},
 	}
 )
 
@@ -54,32 +72,42 @@ func appCommands() []*cli.Command {
 			Name:    "format",
 			Aliases: []string{"fmt"},
 			Usage:   "format Earthfiles in the current repo according to a set of rules",
-			UsageText: "rules to be written:\n" +
+			UsageText: "rules:\n" +
 				"- indentation in Earthfile blocks\n" +
-				"- duplicate COPY commands",
+				"- duplicate COPY commands in contiguous runs of COPY commands (optionally sorted)\n" +
+				"- canonical flag spelling and quoting of args (opt-in)",
 			Action: formatEarthfile,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{Name: "normalize-args", Usage: "canonicalize flag spelling and quoting of command args"},
+			},
 		},
 		{
 			// Draws inspiration from bazel-gazelle:
 			// https://github.com/bazelbuild/bazel-gazelle
-			Name:  "lint",
-			Usage: "lint the current repo according to a set of rules",
-			UsageText: "rules to be written:\n" +
-				"- Earthfile target COPY command for a nonexistent path\n" +
-				"- Go code that imports a Go `main` package\n" +
-				"- Go package import without a corresponding COPY command\n" +
-				"- Dart package import without a corresponding COPY command\n" +
-				"- Go package directory without a corresponding +src target\n",
-			Action: func(cCtx *cli.Context) error {
-				fmt.Println("UNIMPLEMENTED")
-				return nil
+			Name:      "lint",
+			Usage:     "lint the current repo according to a set of rules",
+			UsageText: lintUsageText(),
+			Action:    lintRepo,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{Name: "fix", Usage: "apply the suggested fixes of lint issues to the Earthfiles"},
+				&cli.BoolFlag{
+					Name:  "diff",
+					Usage: "print the suggested fixes of lint issues as a diff, without applying them; text format only",
+				},
+				&cli.StringFlag{
+					Name:  "format",
+					Value: string(lint.FormatText),
+					Usage: "output format of lint issues: " + lint.FormatNames(),
+				},
 			},
 		},
 		{
 			Name:   "changed",
 			Usage:  "analyze a given Earthly target and exit with 0 if it has any changed input files. exit with 1 otherwise.",
 			Action: failIfTargetUnchanged,
-			Flags:  gitDiffArgs,
+			Flags: append([]cli.Flag{
+				&cli.BoolFlag{Name: "json", Usage: "output the changed inputs of the target and the git diff as JSON"},
+			}, gitDiffArgs...),
 		},
 		{
 			// Draws inspiration from bazel-diff
@@ -88,7 +116,20 @@ func appCommands() []*cli.Command {
 			Usage: "analyze a given Earthly target and output the BUILD commands within it that need rebuilding " +
 				"for a given git diff",
 			Action: outputChangedChildBuilds,
-			Flags:  append([]cli.Flag{&cli.BoolFlag{Name: "json"}}, gitDiffArgs...),
+			Flags: append([]cli.Flag{
+				&cli.StringFlag{
+					Name:  "format",
+					Value: string(matrixFormatText),
+					Usage: "output format of the targets which need rebuilding: " + matrixFormatNames(),
+				},
+				&cli.BoolFlag{Name: "json", Usage: "same as --format json"},
+				&cli.StringFlag{
+					Name:  "depth",
+					Value: "1",
+					Usage: "number of nested BUILD levels to descend into, or \"all\"; a target is output instead " +
+						"of the targets it BUILDs if all of them need rebuilding",
+				},
+			}, gitDiffArgs...),
 		},
 		{
 			Name: "matrix-deps",
@@ -96,6 +137,26 @@ func appCommands() []*cli.Command {
 				"for a given set of changed input files",
 			Action: listDependentBuildsForInputs,
 		},
+		{
+			// Draws inspiration from bazel-diff's generate-hashes
+			Name: "hashes",
+			Usage: "output a JSON map from each Earthly target in the current repo to a fingerprint of its recipe, " +
+				"ARGs and input files",
+			Action: outputTargetHashes,
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "write the JSON map to a file"},
+			},
+		},
+		{
+			Name:   "impacted",
+			Usage:  "output the Earthly targets whose fingerprints differ between two outputs of the hashes command",
+			Action: outputImpactedTargets,
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "from", Required: true, Usage: "hashes of the base revision"},
+				&cli.StringFlag{Name: "to", Required: true, Usage: "hashes of the revision to compare"},
+				&cli.BoolFlag{Name: "json"},
+			},
+		},
 		{
 			Name:      "inspect",
 			Aliases:   []string{"inputs"},
@@ -118,6 +179,15 @@ func appCommands() []*cli.Command {
 				&cli.BoolFlag{Name: "include-transitive", Aliases: []string{"transitive"}},
 			},
 		},
+		{
+			Name:      "dartcopies",
+			Usage:     "analyze a given Dart/Flutter package and print the COPY commands its path dependencies need",
+			ArgsUsage: "package path",
+			Action:    printCopyCommandsForDartDeps,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{Name: "include-transitive", Aliases: []string{"transitive"}},
+			},
+		},
 		//{
 		//	Name:  "dlearthly",
 		//	Usage: "download an Earthly binary suitable for the current OS/arch and verify it against a given hash",
@@ -1,19 +1,22 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/earthly/earthly/ast/spec"
 	"github.com/samber/lo"
 	lop "github.com/samber/lo/parallel"
 	"github.com/schollz/progressbar/v3"
 	cli "github.com/urfave/cli/v2"
 
 	"github.com/dorfire/heavenly/pkg/earthfile"
+	"github.com/dorfire/heavenly/pkg/repopath"
 )
 
 func outputChangedChildBuilds(ctx *cli.Context) error {
@@ -22,48 +25,171 @@ func outputChangedChildBuilds(ctx *cli.Context) error {
 		return errors.New("missing Earthly target argument")
 	}
 
+	format, err := parseMatrixFormat(ctx.String("format"))
+	if err != nil {
+		return err
+	}
+	if ctx.Bool("json") {
+		if ctx.IsSet("format") && format != matrixFormatJSON {
+			return fmt.Errorf("--json conflicts with --format %s", format)
+		}
+		format = matrixFormatJSON
+	}
+	depth, err := parseMatrixDepth(ctx.String("depth"))
+	if err != nil {
+		return err
+	}
+
 	ef, target, err := earthfile.ParseTarget(tPath)
 	if err != nil {
 		return err
 	}
 
-	repoChanges, err := gitDiff(ctx, ef.Dir)
+	root, err := repoRoot()
+	if err != nil {
+		return err
+	}
+	repoChanges, err := detectRepoChanges(ctx, root, ef.Dir)
 	if err != nil {
 		return err
 	}
 
-	buildsInTarget := earthfile.CollectBuildCommands(ef, target)
-	progBar := newAnalysisProgressBar(len(buildsInTarget))
+	tree, err := newBuildTree(ef, target, tPath, depth)
+	if err != nil {
+		return err
+	}
 
-	var targetsWithChanges []string
-	lop.ForEach(buildsInTarget, func(t earthfile.BuildCmd, _ int) {
+	// Each target in the tree is analyzed once, even if several targets BUILD it
+	paths := tree.paths()
+	progBar := newAnalysisProgressBar(len(paths))
+	changedByPath := make([]bool, len(paths))
+	errs := make([]error, len(paths))
+	lop.ForEach(paths, func(p string, i int) {
 		// TODO: cache resolved deps across targets?
-		if changed := lo.Must(targetInputsChanged(ctx, repoChanges, t.Target)); changed {
-			targetsWithChanges = append(targetsWithChanges, t.Target)
-		}
+		changedByPath[i], errs[i] = targetInputsChanged(root, repoChanges, p)
 		_ = progBar.Add(1)
 	})
+	if err = firstError(errs); err != nil {
+		return err
+	}
+	changed := make(map[string]bool, len(paths))
+	for i, p := range paths {
+		changed[p] = changedByPath[i]
+	}
+
+	var affected []*buildTree
+	for _, child := range tree.children {
+		affected = append(affected, child.affected(changed).targets...)
+	}
+	m := newMatrix(affected)
 
 	logger.DebugPrintf("Targets with changed inputs:")
-	logger.DebugPrintf(strings.Join(targetsWithChanges, "\n"))
+	logger.DebugPrintf(strings.Join(m.targets(), "\n"))
+
+	return writeMatrix(m, format)
+}
+
+// parseMatrixDepth parses the value of the depth flag: a positive number of BUILD levels, or "all" for unlimited ones,
+// which is returned as 0.
+func parseMatrixDepth(s string) (int, error) {
+	if s == "all" {
+		return 0, nil
+	}
+	res, err := strconv.Atoi(s)
+	if err != nil || res < 1 {
+		return 0, fmt.Errorf("invalid depth %q: expected a positive number or \"all\"", s)
+	}
+	return res, nil
+}
+
+// buildTree is a target, along with the targets it BUILDs, recursively.
+type buildTree struct {
+	path     string               // Path to the target, as accepted by earthfile.ParseTarget
+	call     earthfile.TargetCall // The BUILD command's flags and build args; empty for the root
+	children []*buildTree
+}
+
+// newBuildTree returns the tree of targets BUILT by the given target, down to the given depth, where 0 means
+// unlimited. Targets at the depth are leaves, even if they BUILD others.
+func newBuildTree(ef *earthfile.Earthfile, t *spec.Target, path string, depth int) (*buildTree, error) {
+	c := &buildTreeCollector{depth: depth, ancestors: mapset.NewThreadUnsafeSet[string]()}
+	return c.collect(ef, t, path, earthfile.TargetCall{}, 0)
+}
+
+type buildTreeCollector struct {
+	depth     int
+	ancestors mapset.Set[string] // Paths of the targets being collected, to detect cycles
+}
+
+func (c *buildTreeCollector) collect(
+	ef *earthfile.Earthfile, t *spec.Target, path string, call earthfile.TargetCall, level int,
+) (*buildTree, error) {
+	res := &buildTree{path: path, call: call}
+	if c.depth != 0 && level >= c.depth {
+		return res, nil
+	}
 
-	if !ctx.Bool("json") {
-		logger.Printf(strings.Join(targetsWithChanges, "\n"))
-		return nil
+	if !c.ancestors.Add(path) {
+		return nil, fmt.Errorf("BUILD cycle through %s", path)
 	}
+	defer c.ancestors.Remove(path)
 
-	jsonBytes, err := json.Marshal(targetsWithChanges)
+	builds, err := earthfile.CollectBuildCommands(ef, t)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	logger.PrintBytes(jsonBytes)
 
-	if ghOutputPath := os.Getenv("GITHUB_OUTPUT"); ghOutputPath != "" {
-		logger.DebugPrintf("GitHub env detected; outputting to %s", ghOutputPath)
-		return appendGitHubOutput(ghOutputPath, "targets", string(jsonBytes))
+	for _, b := range builds {
+		childEf, childT, err := earthfile.ParseTarget(b.Path)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: `%s`: %w", ef.Path, b.Line, err)
+		}
+		child, err := c.collect(childEf, childT, b.Path, b.Call, level+1)
+		if err != nil {
+			return nil, err
+		}
+		res.children = append(res.children, child)
 	}
+	return res, nil
+}
 
-	return nil
+// paths returns the unique paths of the targets in the tree, except for its root.
+func (t *buildTree) paths() []string {
+	var res []string
+	for _, c := range t.children {
+		res = append(res, c.path)
+		res = append(res, c.paths()...)
+	}
+	return lo.Uniq(res)
+}
+
+type affectedTargets struct {
+	targets []*buildTree // The targets to rebuild in order to rebuild all affected ones in the tree
+	all     bool         // Whether the whole tree is affected
+}
+
+// affected returns the targets in the tree which need rebuilding, given whether each target's own inputs changed.
+// A target which BUILDs others is returned if all of them are affected, and otherwise its affected descendants are.
+func (t *buildTree) affected(changed map[string]bool) affectedTargets {
+	if changed[t.path] {
+		return affectedTargets{targets: []*buildTree{t}, all: true}
+	}
+	if len(t.children) == 0 {
+		return affectedTargets{}
+	}
+
+	all := true
+	var descendants []*buildTree
+	for _, c := range t.children {
+		a := c.affected(changed)
+		all = all && a.all
+		descendants = append(descendants, a.targets...)
+	}
+
+	if all {
+		return affectedTargets{targets: []*buildTree{t}, all: true}
+	}
+	return affectedTargets{targets: descendants}
 }
 
 func listDependentBuildsForInputs(ctx *cli.Context) error {
@@ -72,30 +198,55 @@ func listDependentBuildsForInputs(ctx *cli.Context) error {
 		return errors.New("missing Earthly target argument")
 	}
 
-	inputPaths := ctx.Args().Tail()
-	if len(inputPaths) == 0 {
+	if ctx.Args().Len() < 2 {
 		return errors.New("missing input file paths")
 	}
 
+	root, err := repoRoot()
+	if err != nil {
+		return err
+	}
+	// Input paths are relative to the current directory, like target paths
+	inputPaths := make([]repopath.Path, 0, ctx.Args().Len()-1)
+	for _, p := range ctx.Args().Tail() {
+		rel, err := root.Rel(p)
+		if err != nil {
+			return err
+		}
+		inputPaths = append(inputPaths, rel)
+	}
+
 	ef, target, err := earthfile.ParseTarget(tPath)
 	if err != nil {
 		return err
 	}
 
-	buildsInTarget := earthfile.CollectBuildCommands(ef, target)
+	buildsInTarget, err := earthfile.CollectBuildCommands(ef, target)
+	if err != nil {
+		return err
+	}
 	progBar := newAnalysisProgressBar(len(buildsInTarget))
 
 	stopTimer := timer(fmt.Sprintf("Analyzing %d targets", len(buildsInTarget)))
-	var dependents []string
-	lop.ForEach(buildsInTarget, func(t earthfile.BuildCmd, _ int) {
+	depends := make([]bool, len(buildsInTarget))
+	errs := make([]error, len(buildsInTarget))
+	lop.ForEach(buildsInTarget, func(t earthfile.BuildCmd, i int) {
 		// TODO: cache resolved deps across targets?
-		buildInputs := lo.Must(analyzeTargetDeps(t.Target))
-		if buildInputs.Contains(inputPaths...) {
-			dependents = append(dependents, t.Target)
-		}
+		buildInputs, err := analyzeTargetDeps(root, t.Path)
+		depends[i], errs[i] = err == nil && buildInputs.Contains(inputPaths...), err
 		_ = progBar.Add(1)
 	})
 	stopTimer()
+	if err = firstError(errs); err != nil {
+		return err
+	}
+
+	var dependents []string
+	for i, t := range buildsInTarget {
+		if depends[i] {
+			dependents = append(dependents, t.Path)
+		}
+	}
 
 	logger.PrintPhaseHeader(
 		fmt.Sprintf("\n%d targets depend on inputs %v:", len(dependents), inputPaths), false, "")
@@ -103,6 +254,12 @@ func listDependentBuildsForInputs(ctx *cli.Context) error {
 	return nil
 }
 
+// firstError returns the first non-nil error of the given ones, or nil.
+func firstError(errs []error) error {
+	err, _ := lo.Find(errs, func(err error) bool { return err != nil })
+	return err
+}
+
 func appendGitHubOutput(path, name, val string) error {
 	ghOutput, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dorfire/heavenly/pkg/earthfile"
+)
+
+func TestParseMatrixDepth(t *testing.T) {
+	for s, want := range map[string]int{"1": 1, "3": 3, "all": 0} {
+		got, err := parseMatrixDepth(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, got, s)
+	}
+	for _, s := range []string{"0", "-1", "x", ""} {
+		_, err := parseMatrixDepth(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestBuildTreeAffected(t *testing.T) {
+	leaf := func(path string) *buildTree { return &buildTree{path: path} }
+	group := func(path string, children ...*buildTree) *buildTree {
+		return &buildTree{path: path, children: children}
+	}
+	// +root BUILDs +grp and +c; +grp BUILDs +a and +b
+	tree := func() *buildTree {
+		return group("+root", group("+grp", leaf("+a"), leaf("+b")), leaf("+c"))
+	}
+
+	for _, tc := range []struct {
+		name    string
+		changed []string
+		want    []string
+	}{
+		{"nothing", nil, nil},
+		{"leaf", []string{"+c"}, []string{"+c"}},
+		{"part of group", []string{"+a"}, []string{"+a"}},
+		{"whole group", []string{"+a", "+b"}, []string{"+grp"}},
+		{"group itself", []string{"+grp"}, []string{"+grp"}},
+		{"group and leaf", []string{"+a", "+b", "+c"}, []string{"+grp", "+c"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			changed := map[string]bool{}
+			for _, p := range tc.changed {
+				changed[p] = true
+			}
+			var got []string
+			for _, c := range tree().children {
+				for _, a := range c.affected(changed).targets {
+					got = append(got, a.path)
+				}
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestNewBuildTree(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"Earthfile":     "VERSION 0.6\nFROM alpine\n\nall:\n    BUILD ./grp+all\n    BUILD ./c+build\n",
+		"grp/Earthfile": "VERSION 0.6\nFROM alpine\n\nall:\n    BUILD ./a+build\n",
+		// A cycle below the default depth
+		"grp/a/Earthfile": "VERSION 0.6\nFROM alpine\n\nbuild:\n    BUILD +other\n\nother:\n    BUILD +build\n",
+		"c/Earthfile":     "VERSION 0.6\nFROM alpine\n\nbuild:\n    RUN true\n",
+	})
+	tPath := dir + "+all"
+	ef, target, err := earthfile.ParseTarget(tPath)
+	require.NoError(t, err)
+
+	// Depth 1 only analyzes the targets BUILT by the given one, like before --depth existed
+	tree, err := newBuildTree(ef, target, tPath, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "grp") + "+all", filepath.Join(dir, "c") + "+build"}, tree.paths())
+	for _, c := range tree.children {
+		assert.Empty(t, c.children)
+	}
+
+	tree, err = newBuildTree(ef, target, tPath, 2)
+	require.NoError(t, err)
+	assert.Len(t, tree.paths(), 3)
+
+	_, err = newBuildTree(ef, target, tPath, 0)
+	assert.ErrorContains(t, err, "BUILD cycle")
+}
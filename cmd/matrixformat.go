@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+	"gopkg.in/yaml.v3"
+
+	"github.com/dorfire/heavenly/pkg/earthfile"
+)
+
+// matrixFormat is an output format of the matrix command.
+type matrixFormat string
+
+const (
+	matrixFormatText      matrixFormat = "text"      // A target per line
+	matrixFormatJSON      matrixFormat = "json"      // An array of targets
+	matrixFormatGitHub    matrixFormat = "github"    // A GitHub Actions matrix of include objects
+	matrixFormatGitLab    matrixFormat = "gitlab"    // A GitLab child pipeline
+	matrixFormatBuildkite matrixFormat = "buildkite" // A Buildkite pipeline, for `buildkite-agent pipeline upload`
+)
+
+var (
+	matrixFormats = []matrixFormat{
+		matrixFormatText, matrixFormatJSON, matrixFormatGitHub, matrixFormatGitLab, matrixFormatBuildkite,
+	}
+
+	// Shell words which don't need quoting
+	shellSafeRe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+)
+
+// The job which CI pipeline formats contain when no target needs rebuilding, as GitLab and Buildkite reject pipelines
+// without jobs. GitHub Actions rejects empty matrices too; its jobs can be skipped by the `empty` output instead.
+const (
+	emptyMatrixJob     = "heavenly-no-changes"
+	emptyMatrixCommand = "echo No targets need rebuilding"
+)
+
+func parseMatrixFormat(s string) (matrixFormat, error) {
+	for _, f := range matrixFormats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown matrix format %q; expected one of %s", s, matrixFormatNames())
+}
+
+// matrixFormatNames returns the names of the supported matrix formats, for usage texts.
+func matrixFormatNames() string {
+	return strings.Join(lo.Map(matrixFormats, func(f matrixFormat, _ int) string { return string(f) }), ", ")
+}
+
+// matrixEntry is a target which needs rebuilding, as it is BUILT by its parent target on a single platform.
+type matrixEntry struct {
+	Target    string            `json:"target"`              // e.g. `./services/api+docker`
+	Earthfile string            `json:"earthfile"`           // The directory of the target's Earthfile
+	Platform  string            `json:"platform,omitempty"`  // The `--platform` of the BUILD command, if any
+	BuildArgs map[string]string `json:"buildArgs,omitempty"` // The build args of the BUILD command
+	Command   string            `json:"command"`             // The earthly command which builds the target
+}
+
+type matrix []matrixEntry
+
+// newMatrix returns the entries of the given targets: one per platform each of them is built on.
+func newMatrix(targets []*buildTree) matrix {
+	res := matrix{}
+	for _, t := range targets {
+		platforms := earthfile.FlagValues(t.call.Flags, "--platform")
+		if len(platforms) == 0 {
+			platforms = []string{""}
+		}
+		dir, _, _ := strings.Cut(t.path, "+")
+		for _, p := range platforms {
+			e := matrixEntry{
+				Target:    t.path,
+				Earthfile: lo.Ternary(dir == "", ".", dir),
+				Platform:  unquoteArg(p),
+				BuildArgs: lo.MapValues(t.call.BuildArgs, func(v, _ string) string { return unquoteArg(v) }),
+			}
+			e.Command = e.earthlyCommand()
+			// The same target may be BUILT by several targets in the tree
+			if !lo.ContainsBy(res, func(o matrixEntry) bool { return o.Command == e.Command }) {
+				res = append(res, e)
+			}
+		}
+	}
+	return res
+}
+
+func (e matrixEntry) earthlyCommand() string {
+	args := []string{"earthly"}
+	if e.Platform != "" {
+		args = append(args, "--platform="+e.Platform)
+	}
+	args = append(args, e.Target)
+	keys := lo.Keys(e.BuildArgs)
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "--"+k+"="+e.BuildArgs[k])
+	}
+	return strings.Join(lo.Map(args, func(a string, _ int) string { return shellQuote(a) }), " ")
+}
+
+// jobName returns a name which identifies the entry among the others, for CI pipelines.
+func (e matrixEntry) jobName() string {
+	if e.Platform == "" {
+		return e.Target
+	}
+	return e.Target + " " + e.Platform
+}
+
+// targets returns the unique targets of the matrix.
+func (m matrix) targets() []string {
+	return lo.Uniq(lo.Map(m, func(e matrixEntry, _ int) string { return e.Target }))
+}
+
+// unquoteArg strips the quotes off an Earthfile argument value, e.g. `"hi there"` -> `hi there`.
+func unquoteArg(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func shellQuote(s string) string {
+	if shellSafeRe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// writeMatrix outputs the matrix in the given format. With the JSON and GitHub formats, it is also appended to
+// $GITHUB_OUTPUT, if set.
+func writeMatrix(m matrix, format matrixFormat) error {
+	var (
+		out []byte
+		err error
+	)
+	switch format {
+	case matrixFormatText:
+		logger.Printf(strings.Join(m.targets(), "\n"))
+		return nil
+	case matrixFormatJSON:
+		out, err = json.Marshal(m.targets())
+	case matrixFormatGitHub:
+		out, err = json.Marshal(gitHubMatrix(m))
+	case matrixFormatGitLab:
+		out, err = yaml.Marshal(gitLabPipeline(m))
+	case matrixFormatBuildkite:
+		out, err = yaml.Marshal(buildkitePipeline(m))
+	default:
+		return fmt.Errorf("unknown matrix format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	logger.PrintBytes(out)
+
+	if ghOutputPath := os.Getenv("GITHUB_OUTPUT"); ghOutputPath != "" &&
+		(format == matrixFormatJSON || format == matrixFormatGitHub) {
+		logger.DebugPrintf("GitHub env detected; outputting to %s", ghOutputPath)
+		return writeGitHubOutputs(ghOutputPath, m)
+	}
+	return nil
+}
+
+func gitHubMatrix(m matrix) any {
+	return map[string]matrix{"include": m}
+}
+
+// writeGitHubOutputs appends the matrix to a GitHub Actions output file as `targets`, an array of targets, `matrix`,
+// to be used as `strategy.matrix: ${{ fromJSON(needs.<job>.outputs.matrix) }}`, and `empty`, which is "true" if no
+// target needs rebuilding, to skip jobs whose matrices would be empty.
+func writeGitHubOutputs(ghOutputPath string, m matrix) error {
+	targets, err := json.Marshal(m.targets())
+	if err != nil {
+		return err
+	}
+	ghMatrix, err := json.Marshal(gitHubMatrix(m))
+	if err != nil {
+		return err
+	}
+	for _, o := range [][2]string{
+		{"targets", string(targets)},
+		{"matrix", string(ghMatrix)},
+		{"empty", fmt.Sprint(len(m) == 0)},
+	} {
+		if err = appendGitHubOutput(ghOutputPath, o[0], o[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type gitLabJob struct {
+	Script    []string          `yaml:"script"`
+	Variables map[string]string `yaml:"variables,omitempty"`
+}
+
+// gitLabPipeline returns a GitLab child pipeline, which runs a job per entry.
+func gitLabPipeline(m matrix) map[string]gitLabJob {
+	if len(m) == 0 {
+		return map[string]gitLabJob{emptyMatrixJob: {Script: []string{emptyMatrixCommand}}}
+	}
+	res := make(map[string]gitLabJob, len(m))
+	for _, e := range m {
+		res[e.jobName()] = gitLabJob{Script: []string{e.Command}, Variables: e.variables()}
+	}
+	return res
+}
+
+type buildkiteStep struct {
+	Label   string            `yaml:"label"`
+	Command string            `yaml:"command"`
+	Env     map[string]string `yaml:"env,omitempty"`
+}
+
+// buildkitePipeline returns a Buildkite pipeline, which runs a step per entry.
+func buildkitePipeline(m matrix) map[string][]buildkiteStep {
+	if len(m) == 0 {
+		return map[string][]buildkiteStep{"steps": {{Label: emptyMatrixJob, Command: emptyMatrixCommand}}}
+	}
+	steps := lo.Map(m, func(e matrixEntry, _ int) buildkiteStep {
+		return buildkiteStep{Label: e.jobName(), Command: e.Command, Env: e.variables()}
+	})
+	return map[string][]buildkiteStep{"steps": steps}
+}
+
+// variables returns the environment variables of the entry's CI job, for scripts which don't run its command as is.
+func (e matrixEntry) variables() map[string]string {
+	res := map[string]string{"HEAVENLY_TARGET": e.Target, "HEAVENLY_EARTHFILE": e.Earthfile}
+	if e.Platform != "" {
+		res["HEAVENLY_PLATFORM"] = e.Platform
+	}
+	return res
+}
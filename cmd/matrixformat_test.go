@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/dorfire/heavenly/pkg/earthfile"
+)
+
+func TestShellQuote(t *testing.T) {
+	for s, want := range map[string]string{
+		"./a+build":              "./a+build",
+		"--platform=linux/amd64": "--platform=linux/amd64",
+		"--MSG=hi there":         "'--MSG=hi there'",
+		"it's":                   `'it'\''s'`,
+		"$HOME":                  "'$HOME'",
+	} {
+		assert.Equal(t, want, shellQuote(s), s)
+	}
+}
+
+func TestUnquoteArg(t *testing.T) {
+	for s, want := range map[string]string{
+		`"hi there"`: "hi there",
+		`'hi'`:       "hi",
+		`"mismatch'`: `"mismatch'`,
+		`"`:          `"`,
+		"plain":      "plain",
+	} {
+		assert.Equal(t, want, unquoteArg(s), s)
+	}
+}
+
+func TestNewMatrix(t *testing.T) {
+	targets := []*buildTree{
+		{path: "./a+build", call: earthfile.TargetCall{
+			Flags:     [][]string{{"--platform=linux/amd64"}, {"--platform", "linux/arm64"}},
+			BuildArgs: map[string]string{"MSG": `"hi there"`, "A": "1"},
+		}},
+		{path: "+local"},
+		// BUILT by another target too
+		{path: "+local"},
+	}
+	assert.Equal(t, matrix{
+		{
+			Target: "./a+build", Earthfile: "./a", Platform: "linux/amd64",
+			BuildArgs: map[string]string{"MSG": "hi there", "A": "1"},
+			Command:   "earthly --platform=linux/amd64 ./a+build --A=1 '--MSG=hi there'",
+		},
+		{
+			Target: "./a+build", Earthfile: "./a", Platform: "linux/arm64",
+			BuildArgs: map[string]string{"MSG": "hi there", "A": "1"},
+			Command:   "earthly --platform=linux/arm64 ./a+build --A=1 '--MSG=hi there'",
+		},
+		{Target: "+local", Earthfile: ".", BuildArgs: map[string]string{}, Command: "earthly +local"},
+	}, newMatrix(targets))
+	assert.Equal(t, matrix{}, newMatrix(nil))
+}
+
+var testMatrix = matrix{
+	{Target: "./a+build", Earthfile: "./a", Platform: "linux/amd64", Command: "earthly --platform=linux/amd64 ./a+build"},
+	{Target: "./b+build", Earthfile: "./b", Command: "earthly ./b+build"},
+}
+
+func TestGitHubMatrix(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		m    matrix
+		want string
+	}{
+		{"entries", testMatrix, `{"include":[` +
+			`{"target":"./a+build","earthfile":"./a","platform":"linux/amd64",` +
+			`"command":"earthly --platform=linux/amd64 ./a+build"},` +
+			`{"target":"./b+build","earthfile":"./b","command":"earthly ./b+build"}]}`},
+		{"empty", matrix{}, `{"include":[]}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := json.Marshal(gitHubMatrix(tc.m))
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.want, string(out))
+		})
+	}
+}
+
+func TestGitLabPipeline(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		m    matrix
+		want string
+	}{
+		{"entries", testMatrix, `./a+build linux/amd64:
+    script:
+        - earthly --platform=linux/amd64 ./a+build
+    variables:
+        HEAVENLY_EARTHFILE: ./a
+        HEAVENLY_PLATFORM: linux/amd64
+        HEAVENLY_TARGET: ./a+build
+./b+build:
+    script:
+        - earthly ./b+build
+    variables:
+        HEAVENLY_EARTHFILE: ./b
+        HEAVENLY_TARGET: ./b+build
+`},
+		{"empty", matrix{}, `heavenly-no-changes:
+    script:
+        - echo No targets need rebuilding
+`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := yaml.Marshal(gitLabPipeline(tc.m))
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(out))
+		})
+	}
+}
+
+func TestBuildkitePipeline(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		m    matrix
+		want string
+	}{
+		{"entries", testMatrix, `steps:
+    - label: ./a+build linux/amd64
+      command: earthly --platform=linux/amd64 ./a+build
+      env:
+        HEAVENLY_EARTHFILE: ./a
+        HEAVENLY_PLATFORM: linux/amd64
+        HEAVENLY_TARGET: ./a+build
+    - label: ./b+build
+      command: earthly ./b+build
+      env:
+        HEAVENLY_EARTHFILE: ./b
+        HEAVENLY_TARGET: ./b+build
+`},
+		{"empty", matrix{}, `steps:
+    - label: heavenly-no-changes
+      command: echo No targets need rebuilding
+`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := yaml.Marshal(buildkitePipeline(tc.m))
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(out))
+		})
+	}
+}
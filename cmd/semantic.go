@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/earthly/earthly/ast/spec"
+	"github.com/samber/lo"
+	cli "github.com/urfave/cli/v2"
+
+	"github.com/dorfire/heavenly/pkg/earthfile"
+	"github.com/dorfire/heavenly/pkg/gitutil"
+	"github.com/dorfire/heavenly/pkg/repopath"
+)
+
+const (
+	earthfileName = "Earthfile"
+)
+
+// repoChanges is what a git diff changed in the repo, which targets are checked against.
+type repoChanges struct {
+	files gitutil.ChangeSet
+	// recipes holds the keys of the targets whose recipes changed, if Earthfiles are compared semantically; in that
+	// case, changed Earthfiles aren't changed inputs by themselves. It's nil otherwise.
+	recipes mapset.Set[string]
+}
+
+// detectRepoChanges diffs the repo per the git diff flags, and compares the recipes in changed Earthfiles if the
+// semantic flag is set.
+func detectRepoChanges(ctx *cli.Context, root repopath.Root, pathInRepo string) (repoChanges, error) {
+	diff, err := gitDiff(ctx, pathInRepo)
+	if err != nil || !ctx.Bool("semantic") {
+		return repoChanges{files: diff}, err
+	}
+
+	recipes, err := changedRecipes(root, diff)
+	if err != nil {
+		return repoChanges{}, err
+	}
+	logger.DebugPrintf("📜 Changed recipes: %v", recipes)
+	return repoChanges{diff, recipes}, nil
+}
+
+// changedFiles returns the changed files which target inputs are matched against.
+func (c repoChanges) changedFiles() mapset.Set[repopath.Path] {
+	res := c.files.All()
+	if c.recipes == nil {
+		return res
+	}
+	return mapset.NewSet(lo.Filter(res.ToSlice(), func(p repopath.Path, _ int) bool {
+		return path.Base(string(p)) != earthfileName
+	})...)
+}
+
+// changedRecipes returns the keys of the targets whose recipes differ between both sides of the given diff, in the
+// Earthfiles it changed.
+func changedRecipes(root repopath.Root, diff gitutil.ChangeSet) (mapset.Set[string], error) {
+	repo, err := gitutil.OpenRepo(root.Dir())
+	if err != nil {
+		return nil, err
+	}
+
+	res := mapset.NewSet[string]()
+	for _, p := range diff.All().ToSlice() {
+		if path.Base(string(p)) != earthfileName {
+			continue
+		}
+
+		before, err := recipeDigestsAt(root, p, func() ([]byte, error) { return gitutil.FileAt(repo, diff.Base, p) })
+		if err != nil {
+			return nil, fmt.Errorf("at %s: %w", diff.Base, err)
+		}
+		after, err := recipeDigestsAt(root, p, func() ([]byte, error) {
+			switch diff.Uncommitted {
+			case gitutil.DiffStaged:
+				return gitutil.StagedFile(repo, p)
+			case gitutil.DiffWorktree:
+				return os.ReadFile(root.Abs(p))
+			default:
+				return gitutil.FileAt(repo, diff.Target, p)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		dir := repopath.FromSlash(path.Dir(string(p)))
+		for name := range lo.Assign(before, after) {
+			if before[name] != after[name] {
+				res.Add(targetKeyIn(dir, name))
+			}
+		}
+	}
+	return res, nil
+}
+
+// recipeDigestsAt returns the recipe digests of the Earthfile at the given path, as read by the given function; or
+// nil if it doesn't exist.
+func recipeDigestsAt(root repopath.Root, p repopath.Path, read func() ([]byte, error)) (map[string]string, error) {
+	src, err := read()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ef, err := earthfile.ParseSource(root.Abs(p), src)
+	if err != nil {
+		return nil, err
+	}
+	return recipeDigests(ef), nil
+}
+
+// recipeDigests returns a digest of the recipe of each target and user-defined command in the given Earthfile, by
+// name. Target digests cover the base recipe, and every digest covers the Earthfile's VERSION and IMPORT commands and
+// the values of the global ARGs the recipe, or the base recipe for targets, references.
+func recipeDigests(ef *earthfile.Earthfile) map[string]string {
+	header := earthfile.HeaderText(ef)
+	// ARG declarations in the base recipe only matter to targets which reference them, but every target inherits the
+	// commands which do, like `FROM golang:$GO_VERSION`
+	baseRecipe := earthfile.RecipeText(lo.Filter(ef.Spec.BaseRecipe, func(s spec.Statement, _ int) bool {
+		return s.Command == nil || s.Command.Name != "ARG"
+	}))
+	baseArgRefs := earthfile.ArgRefs(baseRecipe)
+
+	globals := func(recipe string, argRefs []string) string {
+		var res []string
+		for _, name := range lo.Uniq(append(earthfile.ArgRefs(recipe), argRefs...)) {
+			if val, ok := ef.Globals[name]; ok {
+				res = append(res, name+"="+val)
+			}
+		}
+		sort.Strings(res)
+		return strings.Join(res, "\n")
+	}
+
+	res := map[string]string{}
+	for _, t := range ef.Spec.Targets {
+		recipe := earthfile.RecipeText(t.Recipe)
+		res[t.Name] = strings.Join([]string{header, baseRecipe, recipe, globals(recipe, baseArgRefs)}, "\n---\n")
+	}
+	// User-defined commands run in the context of their callers, so they don't inherit the base recipe
+	for _, c := range ef.Spec.UserCommands {
+		recipe := earthfile.RecipeText(c.Recipe)
+		res[c.Name] = strings.Join([]string{header, recipe, globals(recipe, nil)}, "\n---\n")
+	}
+	return res
+}
+
+// targetRecipeChanges returns the keys of the given target, the targets it depends on via FROM and COPY commands and
+// the user-defined commands it calls, whose recipes are among the given changed ones.
+func targetRecipeChanges(
+	root repopath.Root, recipes mapset.Set[string], ef *earthfile.Earthfile, t *spec.Target, seen mapset.Set[string],
+) ([]string, error) {
+	key, err := targetKey(root, ef, t.Name)
+	if err != nil || !seen.Add(key) {
+		return nil, err
+	}
+
+	var res []string
+	if recipes.Contains(key) {
+		res = append(res, key)
+	}
+
+	refs, err := earthfile.CollectTargetRefs(ef, t)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		if ref.Cmd == "BUILD" { // BUILD targets are built separately; they aren't part of the target's output
+			continue
+		}
+		refEf, refT, err := ef.Target(ref.Target)
+		if err != nil {
+			return nil, fmt.Errorf("%s: could not find target %s: %w", key, ref.Target, err)
+		}
+		changed, err := targetRecipeChanges(root, recipes, refEf, refT, seen)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, changed...)
+	}
+
+	changed, err := commandRecipeChanges(root, recipes, ef, t.Recipe, seen)
+	if err != nil {
+		return nil, err
+	}
+	return append(res, changed...), nil
+}
+
+// commandRecipeChanges returns the keys of the user-defined commands the given recipe calls, following nested calls,
+// whose recipes are among the given changed ones.
+func commandRecipeChanges(
+	root repopath.Root, recipes mapset.Set[string], ef *earthfile.Earthfile, recipe spec.Block, seen mapset.Set[string],
+) ([]string, error) {
+	calls, err := earthfile.CollectCommandCalls(ef, recipe)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for _, ref := range calls {
+		cmdEf, cmd, err := ef.UserCommand(ref)
+		if err != nil {
+			return nil, err
+		}
+		key, err := targetKey(root, cmdEf, cmd.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen.Add(key) {
+			continue
+		}
+		if recipes.Contains(key) {
+			res = append(res, key)
+		}
+		changed, err := commandRecipeChanges(root, recipes, cmdEf, cmd.Recipe, seen)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, changed...)
+	}
+	return res, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dorfire/heavenly/pkg/earthfile"
+	"github.com/dorfire/heavenly/pkg/gitutil"
+	"github.com/dorfire/heavenly/pkg/repopath"
+)
+
+// writeFiles writes the given files under dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for p, content := range files {
+		abs := filepath.Join(dir, p)
+		require.NoError(t, os.MkdirAll(filepath.Dir(abs), 0o755))
+		require.NoError(t, os.WriteFile(abs, []byte(content), 0o644))
+	}
+}
+
+// commitFiles writes and stages the given files, and commits them unless msg is empty.
+func commitFiles(t *testing.T, repo *git.Repository, files map[string]string, msg string) plumbing.Hash {
+	t.Helper()
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	writeFiles(t, wt.Filesystem.Root(), files)
+	for p := range files {
+		_, err = wt.Add(p)
+		require.NoError(t, err)
+	}
+	if msg == "" {
+		return plumbing.ZeroHash
+	}
+
+	sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Unix(1700000000, 0)}
+	h, err := wt.Commit(msg, &git.CommitOptions{Author: sig})
+	require.NoError(t, err)
+	return h
+}
+
+func earthfileWith(a, b string) string {
+	return "VERSION 0.6\nFROM alpine\n\na:\n    RUN echo " + a + "\n\nb:\n    RUN echo " + b + "\n"
+}
+
+func TestChangedRecipes(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	root, err := repopath.NewRoot(dir)
+	require.NoError(t, err)
+
+	base := commitFiles(t, repo, map[string]string{"Earthfile": earthfileWith("a", "b")}, "base")
+	target := commitFiles(t, repo, map[string]string{"Earthfile": earthfileWith("a2", "b")}, "target")
+	commitFiles(t, repo, map[string]string{"Earthfile": earthfileWith("a2", "b2")}, "") // Staged
+	writeFiles(t, dir, map[string]string{"Earthfile": earthfileWith("a3", "b2")})       // Unstaged
+
+	for _, tc := range []struct {
+		name     string
+		from, to plumbing.Hash
+		target   gitutil.DiffTarget
+		want     []string
+	}{
+		{"commits", base, target, gitutil.DiffCommit, []string{"+a"}},
+		{"older commits", base, base, gitutil.DiffCommit, nil},
+		{"staged", target, target, gitutil.DiffStaged, []string{"+b"}},
+		{"worktree", target, target, gitutil.DiffWorktree, []string{"+a", "+b"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			diff, err := gitutil.FilesChanged(context.Background(), repo, tc.from.String(), tc.to.String(),
+				gitutil.DiffOptions{Target: tc.target})
+			require.NoError(t, err)
+			recipes, err := changedRecipes(root, diff)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.want, recipes.ToSlice())
+		})
+	}
+}
+
+func TestRecipeDigests(t *testing.T) {
+	digests := func(goVersion, other, a string) map[string]string {
+		src := "VERSION 0.6\nARG GO_VERSION=" + goVersion + "\nFROM golang:$GO_VERSION\nARG OTHER=" + other + "\n\n" +
+			"a:\n    RUN " + a + "\n\nb:\n    RUN echo $OTHER\n"
+		ef, err := earthfile.ParseSource(filepath.Join(t.TempDir(), "Earthfile"), []byte(src))
+		require.NoError(t, err)
+		return recipeDigests(ef)
+	}
+	changed := func(before, after map[string]string) []string {
+		var res []string
+		for name := range before {
+			if before[name] != after[name] {
+				res = append(res, name)
+			}
+		}
+		return res
+	}
+
+	base := digests("1.20", "x", "true")
+	assert.Empty(t, changed(base, digests("1.20", "x", "true")))
+	// Every target inherits the base image
+	assert.ElementsMatch(t, []string{"a", "b"}, changed(base, digests("1.21", "x", "true")))
+	// Only b references OTHER
+	assert.ElementsMatch(t, []string{"b"}, changed(base, digests("1.20", "y", "true")))
+	assert.ElementsMatch(t, []string{"a"}, changed(base, digests("1.20", "x", "false")))
+}
+
+func TestRecipeChangesInUserCommands(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	root, err := repopath.NewRoot(dir)
+	require.NoError(t, err)
+
+	earthfileWithCommand := func(version, msg string) string {
+		return "VERSION " + version + "\nFROM alpine\n\n" +
+			"a:\n    DO +OUTER\n\nb:\n    RUN true\n\n" +
+			"OUTER:\n    COMMAND\n    DO +INNER\n\nINNER:\n    COMMAND\n    RUN echo " + msg + "\n"
+	}
+	base := commitFiles(t, repo, map[string]string{"Earthfile": earthfileWithCommand("0.6", "a")}, "base")
+
+	for _, tc := range []struct {
+		name      string
+		earthfile string
+		want      map[string][]string // Changed recipes by target
+	}{
+		{"nested command", earthfileWithCommand("0.6", "b"), map[string][]string{"+a": {"+INNER"}, "+b": nil}},
+		{"version", earthfileWithCommand("0.5", "a"), map[string][]string{
+			"+a": {"+a", "+OUTER", "+INNER"}, "+b": {"+b"},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			writeFiles(t, dir, map[string]string{"Earthfile": tc.earthfile})
+			diff, err := gitutil.FilesChanged(context.Background(), repo, base.String(), base.String(),
+				gitutil.DiffOptions{Target: gitutil.DiffWorktree})
+			require.NoError(t, err)
+			recipes, err := changedRecipes(root, diff)
+			require.NoError(t, err)
+
+			changes := repoChanges{files: diff, recipes: recipes}
+			for target, want := range tc.want {
+				got, err := detectTargetChanges(root, changes, dir+target)
+				require.NoError(t, err)
+				assert.ElementsMatch(t, want, got.recipes, target)
+				assert.Empty(t, got.inputs.ToSlice(), target)
+			}
+		})
+	}
+}
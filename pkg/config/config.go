@@ -0,0 +1,59 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/dorfire/heavenly/pkg/earthfilefmt"
+	"github.com/dorfire/heavenly/pkg/lint"
+)
+
+const (
+	FileName = ".heavenly.yaml"
+)
+
+// Config is the project-level configuration of heavenly, read from a FileName at the repo root.
+type Config struct {
+	Format earthfilefmt.Options `yaml:"format"`
+	Lint   lint.Config          `yaml:"lint"`
+}
+
+func Default() *Config {
+	return &Config{
+		Format: earthfilefmt.DefaultOptions(),
+	}
+}
+
+// Load reads the config file in the given repo root. Options missing from the file, or the file itself, fall back to
+// their defaults.
+func Load(repoRoot string) (*Config, error) {
+	cfg := Default()
+
+	p := filepath.Join(repoRoot, FileName)
+	content, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: could not read %s: %w", p, err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("config: could not parse %s: %w", p, err)
+	}
+
+	if err := cfg.Format.Validate(); err != nil {
+		return nil, fmt.Errorf("config: invalid %s: %w", p, err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,62 @@
+package dartdepresolver
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	mapset "github.com/deckarep/golang-set/v2"
+)
+
+var (
+	packageImportRe = regexp.MustCompile(`^\s*(?:import|export)\s+['"]package:([a-zA-Z0-9_]+)/`)
+
+	srcDirs  = []string{"lib", "bin"}
+	testDirs = []string{"test", "integration_test"}
+)
+
+// PackageImports returns the names of the packages imported by the Dart files of the package in the given dir.
+// If test is true, only test files are considered; otherwise, only library and executable files are.
+func PackageImports(dir string, test bool) (mapset.Set[string], error) {
+	subdirs := srcDirs
+	if test {
+		subdirs = testDirs
+	}
+
+	res := mapset.NewThreadUnsafeSet[string]()
+	for _, d := range subdirs {
+		err := filepath.WalkDir(filepath.Join(dir, d), func(p string, ent fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if ent.IsDir() || !strings.HasSuffix(ent.Name(), ".dart") {
+				return nil
+			}
+			return collectFileImports(p, res)
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func collectFileImports(path string, dst mapset.Set[string]) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if m := packageImportRe.FindStringSubmatch(s.Text()); m != nil {
+			dst.Add(m[1])
+		}
+	}
+	return s.Err()
+}
@@ -0,0 +1,70 @@
+package dartdepresolver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	pubspecName = "pubspec.yaml"
+)
+
+// Pubspec is the subset of a Dart package's pubspec.yaml that matters for dependency resolution.
+type Pubspec struct {
+	Name            string                `yaml:"name"`
+	Dependencies    map[string]Dependency `yaml:"dependencies"`
+	DevDependencies map[string]Dependency `yaml:"dev_dependencies"`
+}
+
+// Dependency is a pubspec dependency. Only path dependencies, which refer to packages in the same repo, have a Path.
+type Dependency struct {
+	Path string `yaml:"path"`
+}
+
+func (d *Dependency) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode { // A version constraint, e.g. `^1.2.0`
+		return nil
+	}
+	type plain Dependency
+	return n.Decode((*plain)(d))
+}
+
+func HasPubspec(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, pubspecName))
+	return err == nil
+}
+
+func ReadPubspec(dir string) (*Pubspec, error) {
+	p := filepath.Join(dir, pubspecName)
+
+	content, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", p, err)
+	}
+
+	res := &Pubspec{}
+	if err := yaml.Unmarshal(content, res); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", p, err)
+	}
+	return res, nil
+}
+
+// PathDependencies returns the dirs of the path dependencies in the given dependency map, by package name.
+// Relative paths are resolved against dir.
+func PathDependencies(dir string, deps map[string]Dependency) map[string]string {
+	res := map[string]string{}
+	for name, d := range deps {
+		if d.Path == "" {
+			continue
+		}
+		if filepath.IsAbs(d.Path) {
+			res[name] = filepath.Clean(d.Path)
+		} else {
+			res[name] = filepath.Join(dir, d.Path)
+		}
+	}
+	return res
+}
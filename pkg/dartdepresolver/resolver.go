@@ -0,0 +1,188 @@
+package dartdepresolver
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/earthly/earthly/ast/spec"
+	"github.com/earthly/earthly/conslogging"
+
+	"github.com/dorfire/heavenly/pkg/earthdir"
+)
+
+type pathDep struct {
+	name       string
+	transitive bool
+}
+
+type pathDeps map[string]pathDep // dir -> dependency
+
+type DartDepResolver struct {
+	projRoot string
+	log      conslogging.ConsoleLogger
+}
+
+func New(pkgDir string, log conslogging.ConsoleLogger) (*DartDepResolver, error) {
+	pkgDirAbs, err := filepath.Abs(pkgDir)
+	if err != nil {
+		return nil, err
+	}
+
+	projRoot, err := earthdir.InOrAbove(pkgDirAbs, "/", false)
+	if err != nil {
+		return nil, fmt.Errorf("could not find top-most Earthfile from %s: %w", pkgDirAbs, err)
+	}
+	log.DebugPrintf("Detected project root: %s", projRoot)
+
+	return &DartDepResolver{projRoot, log}, nil
+}
+
+// ProjectRoot returns the dir of the top-most Earthfile above the Dart package, which $TOP refers to in resolved COPY
+// commands.
+func (r *DartDepResolver) ProjectRoot() string {
+	return r.projRoot
+}
+
+// ResolveImportsToCopyCommands resolves the path dependencies of the Dart package in pkgPath to the COPY commands they
+// probably depend on. Like its Go counterpart, it presumes every dependency dir has an Earthfile in it, or above it,
+// with a +src target.
+// `pub get` needs every path dependency in pubspec.yaml, imported or not. Imports only decide whether a dependency is
+// needed by library code, even if it's declared under dev_dependencies.
+// If includeTransitive is true, this func will recursively collect the path dependencies of path dependencies.
+func (r *DartDepResolver) ResolveImportsToCopyCommands(
+	pkgPath string, includeTransitive bool,
+) (copyCmds []spec.Command, devOnlyCopyCmds []spec.Command, err error) {
+	pkgPathAbs, err := filepath.Abs(pkgPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pubspec, err := ReadPubspec(pkgPathAbs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	imports, err := PackageImports(pkgPathAbs, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	testImports, err := PackageImports(pkgPathAbs, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	deps := directPathDeps(pkgPathAbs, pubspec.Dependencies)
+	devDeps := directPathDeps(pkgPathAbs, pubspec.DevDependencies)
+	for dir, d := range devDeps {
+		if imports.Contains(d.name) {
+			deps[dir] = d
+		}
+	}
+
+	if includeTransitive {
+		if err := r.collectTransitiveDeps(deps); err != nil {
+			return nil, nil, err
+		}
+		if err := r.collectTransitiveDeps(devDeps); err != nil {
+			return nil, nil, err
+		}
+	}
+	markImported(deps, imports)
+	markImported(devDeps, imports.Union(testImports))
+
+	for dir := range deps {
+		delete(devDeps, dir)
+	}
+
+	copyCmds, err = r.resolveCopyCommands(deps, pkgPathAbs)
+	if err != nil {
+		return nil, nil, err
+	}
+	devOnlyCopyCmds, err = r.resolveCopyCommands(devDeps, pkgPathAbs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return
+}
+
+// collectTransitiveDeps adds the non-dev path dependencies of the given deps to them, recursively.
+func (r *DartDepResolver) collectTransitiveDeps(deps pathDeps) error {
+	queue := make([]string, 0, len(deps))
+	for dir := range deps {
+		queue = append(queue, dir)
+	}
+
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+
+		pubspec, err := ReadPubspec(dir)
+		if err != nil {
+			return err
+		}
+		for depDir, d := range directPathDeps(dir, pubspec.Dependencies) {
+			if _, ok := deps[depDir]; ok {
+				continue
+			}
+			r.log.DebugPrintf("Resolving transitive path dependency %q from %q", d.name, dir)
+			d.transitive = true
+			deps[depDir] = d
+			queue = append(queue, depDir)
+		}
+	}
+	return nil
+}
+
+func (r *DartDepResolver) resolveCopyCommands(deps pathDeps, pkgPathAbs string) ([]spec.Command, error) {
+	dirs := make([]string, 0, len(deps))
+	for dir := range deps {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	res := make([]spec.Command, 0, len(dirs))
+	for _, dir := range dirs {
+		resolvedEarthdir, err := earthdir.InOrAbove(dir, filepath.Dir(r.projRoot), true)
+		if err != nil {
+			return nil, err
+		}
+
+		src, err := earthdir.SrcArtifact(r.projRoot, dir, resolvedEarthdir)
+		if err != nil {
+			return nil, err
+		}
+
+		// Path dependencies are resolved relative to the package, so they're copied to the same relative path
+		dest, err := filepath.Rel(pkgPathAbs, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		args := []string{"--dir", src, dest + "/"}
+		if deps[dir].transitive {
+			args = append(args, "# indirect")
+		}
+		res = append(res, spec.Command{Name: "COPY", Args: args})
+	}
+	return res, nil
+}
+
+func directPathDeps(dir string, deps map[string]Dependency) pathDeps {
+	res := pathDeps{}
+	for name, depDir := range PathDependencies(dir, deps) {
+		res[depDir] = pathDep{name: name}
+	}
+	return res
+}
+
+// markImported marks transitive deps that are imported directly as non-transitive.
+func markImported(deps pathDeps, imports mapset.Set[string]) {
+	for dir, d := range deps {
+		if d.transitive && imports.Contains(d.name) {
+			d.transitive = false
+			deps[dir] = d
+		}
+	}
+}
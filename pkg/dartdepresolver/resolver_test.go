@@ -0,0 +1,46 @@
+package dartdepresolver_test
+
+import (
+	"testing"
+
+	"github.com/earthly/earthly/ast/spec"
+	"github.com/earthly/earthly/conslogging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dorfire/heavenly/pkg/dartdepresolver"
+)
+
+const appDir = "testdata/apps/app"
+
+func TestPackageImports(t *testing.T) {
+	imports, err := dartdepresolver.PackageImports(appDir, false)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"flutter", "ui", "core"}, imports.ToSlice())
+
+	testImports, err := dartdepresolver.PackageImports(appDir, true)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"testing"}, testImports.ToSlice())
+}
+
+func TestResolveImportsToCopyCommands(t *testing.T) {
+	r, err := dartdepresolver.New(appDir, conslogging.Current(conslogging.NoColor, conslogging.DefaultPadding, conslogging.Info))
+	require.NoError(t, err)
+
+	copies, devCopies, err := r.ResolveImportsToCopyCommands(appDir, false)
+	require.NoError(t, err)
+	assert.Equal(t, []spec.Command{
+		{Name: "COPY", Args: []string{"--dir", "$TOP/packages/ui/+src/*", "../../packages/ui/"}},
+	}, copies)
+	assert.Equal(t, []spec.Command{
+		{Name: "COPY", Args: []string{"--dir", "$TOP/packages/testing/+src/*", "../../packages/testing/"}},
+	}, devCopies)
+
+	// core is a path dependency of ui, imported directly by the app
+	copies, _, err = r.ResolveImportsToCopyCommands(appDir, true)
+	require.NoError(t, err)
+	assert.Equal(t, []spec.Command{
+		{Name: "COPY", Args: []string{"--dir", "$TOP/packages/core/+src/*", "../../packages/core/"}},
+		{Name: "COPY", Args: []string{"--dir", "$TOP/packages/ui/+src/*", "../../packages/ui/"}},
+	}, copies)
+}
@@ -0,0 +1,5 @@
+import 'package:flutter/material.dart';
+import 'package:ui/ui.dart';
+import "package:core/core.dart";
+
+void main() {}
@@ -0,0 +1 @@
+import 'package:testing/testing.dart';
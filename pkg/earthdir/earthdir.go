@@ -39,3 +39,24 @@ func InOrAbove(dir string, upToDir string, closest bool) (string, error) {
 
 	return lastFoundDir, nil
 }
+
+// SrcArtifact formats a reference to the files of dirInProject in the +src target of its closest Earthfile dir, with
+// the project root replaced by $TOP; e.g. `$TOP/libs/+src/foo/*`.
+func SrcArtifact(projRoot, dirInProject, closestEarthdir string) (string, error) {
+	relToClosestEarthfile, err := filepath.Rel(closestEarthdir, dirInProject)
+	if err != nil {
+		return "", err
+	}
+
+	srcDirWithTopArg := WithTopArg(closestEarthdir, projRoot)
+
+	if relToClosestEarthfile != "." {
+		return fmt.Sprintf("%s/+src/%s/*", srcDirWithTopArg, relToClosestEarthfile), nil
+	}
+	return fmt.Sprintf("%s/+src/*", srcDirWithTopArg), nil
+}
+
+// WithTopArg replaces the project root prefix of s with $TOP.
+func WithTopArg(s, projRoot string) string {
+	return strings.Replace(s, projRoot, "$TOP", 1)
+}
@@ -0,0 +1,200 @@
+package earthfile
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/samber/lo"
+)
+
+var (
+	// Earthly flags which take their value as a separate argument when not spelled `--flag=value`
+	flagsWithValue = mapset.NewThreadUnsafeSet(
+		"--platform", "--build-arg", "--chown", "--chmod", "--from", "--mount", "--secret", "--id",
+		"--load", "--compose", "--service", "--pull", "--cache-hint", "--sharing",
+	)
+
+	// References to variables, like `$FOO` or `${FOO}`, which aren't escaped like `\$FOO`
+	argRefRe = regexp.MustCompile(`(?:^|[^\\])\$\{?([A-Za-z_][A-Za-z0-9_]*)`)
+)
+
+// CopyArgs is the structure of a COPY command's args.
+type CopyArgs struct {
+	Flags   [][]string // Leading flags, each along with its value if it has a separate one
+	Sources []string   // Parenthesized sources, like `(+target/artifact --arg=val)`, are joined to a single source
+	Dest    string
+}
+
+// SplitFlags groups the flags at the beginning of args, each along with its value if it has a separate one.
+// It returns the grouped flags and the remaining (positional) args.
+func SplitFlags(args []string) (flags [][]string, rest []string) {
+	i := 0
+	for i < len(args) && strings.HasPrefix(args[i], "-") && args[i] != "-" {
+		a := args[i]
+		if !strings.Contains(a, "=") && flagsWithValue.Contains(a) && i+1 < len(args) {
+			flags = append(flags, args[i:i+2])
+			i += 2
+			continue
+		}
+		flags = append(flags, args[i:i+1])
+		i++
+	}
+	return flags, args[i:]
+}
+
+// HasFlag returns whether the given grouped flags include a flag by the given name, e.g. "--dir".
+func HasFlag(flags [][]string, name string) bool {
+	for _, f := range flags {
+		if n, _, _ := strings.Cut(f[0], "="); n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// FlagValues returns the values of each occurrence of a flag by the given name in the given grouped flags, e.g. the
+// platforms of `BUILD --platform=linux/amd64 --platform linux/arm64 +target`.
+func FlagValues(flags [][]string, name string) []string {
+	var res []string
+	for _, f := range flags {
+		n, v, hasValue := strings.Cut(f[0], "=")
+		switch {
+		case n != name:
+		case hasValue:
+			res = append(res, v)
+		case len(f) > 1:
+			res = append(res, f[1])
+		}
+	}
+	return res
+}
+
+func ParseCopyArgs(args []string) (CopyArgs, error) {
+	flags, rest := SplitFlags(args)
+
+	var positional []string
+	depth := 0
+	for _, a := range rest {
+		if depth > 0 {
+			positional[len(positional)-1] += " " + a
+		} else {
+			positional = append(positional, a)
+		}
+		depth += strings.Count(a, "(") - strings.Count(a, ")")
+	}
+
+	if len(positional) < 2 {
+		return CopyArgs{}, errors.New("earthfile: COPY needs at least one source and a destination")
+	}
+
+	return CopyArgs{Flags: flags, Sources: positional[:len(positional)-1], Dest: positional[len(positional)-1]}, nil
+}
+
+// Args flattens the COPY args back to the form of spec.Command args.
+func (a CopyArgs) Args() []string {
+	res := lo.Flatten(a.Flags)
+	res = append(res, a.Sources...)
+	return append(res, a.Dest)
+}
+
+// UnwrapArtifactRef strips the parentheses and build args off an artifact reference, e.g.
+// `(+target/artifact --arg=val)` -> `+target/artifact`. Other strings are returned as is.
+func UnwrapArtifactRef(src string) string {
+	if !strings.HasPrefix(src, "(") {
+		return src
+	}
+	ref, _, _ := strings.Cut(strings.Trim(src, "()"), " ")
+	return ref
+}
+
+// IsLocalTargetRef returns whether s references a target in the repo, like `+target`, `./dir+target/artifact` or
+// `$TOP/dir+target`, as opposed to a path, an image, or a remote/imported target.
+func IsLocalTargetRef(s string) bool {
+	dir, _, hasPlus := strings.Cut(s, "+")
+	return hasPlus && (dir == "" || strings.HasPrefix(dir, ".") || strings.HasPrefix(dir, "/") || strings.HasPrefix(dir, "$"))
+}
+
+// SplitArtifactRef splits an artifact reference to its target and the artifact path within it, e.g.
+// `../dir+target/bin/*` -> `../dir+target`, `/bin/*`.
+func SplitArtifactRef(ref string) (target, artifact string) {
+	plusPos := strings.IndexRune(ref, '+')
+	if plusPos == -1 {
+		return ref, ""
+	}
+	slashPos := strings.IndexRune(ref[plusPos:], '/')
+	if slashPos == -1 {
+		return ref, ""
+	}
+	return ref[:plusPos+slashPos], ref[plusPos+slashPos:]
+}
+
+// ArgDecl is the structure of an ARG command's args, e.g. `ARG --required NAME` or `ARG NAME = default`.
+type ArgDecl struct {
+	Name       string
+	Default    string
+	HasDefault bool
+	Required   bool
+	Global     bool
+}
+
+func ParseArgDecl(args []string) (ArgDecl, error) {
+	flags, rest := SplitFlags(args)
+	res := ArgDecl{Required: HasFlag(flags, "--required"), Global: HasFlag(flags, "--global")}
+
+	switch {
+	case len(rest) == 1 && strings.Contains(rest[0], "="):
+		res.Name, res.Default, _ = strings.Cut(rest[0], "=")
+		res.HasDefault = true
+	case len(rest) == 1:
+		res.Name = rest[0]
+	case len(rest) == 3 && rest[1] == "=":
+		res.Name, res.Default, res.HasDefault = rest[0], rest[2], true
+	default:
+		return ArgDecl{}, fmt.Errorf("earthfile: unexpected ARG syntax: %v", args)
+	}
+	return res, nil
+}
+
+// ArgRefs returns the names of the variables referenced in s, e.g. `$TOP/+src/${DIR}` -> TOP, DIR.
+func ArgRefs(s string) []string {
+	return lo.Map(argRefRe.FindAllStringSubmatch(s, -1), func(m []string, _ int) string { return m[1] })
+}
+
+// TargetCall is the structure of the args of a command which invokes a target, like BUILD or FROM.
+type TargetCall struct {
+	Flags     [][]string        // Flags before the target
+	Target    string            // The target reference, e.g. `./dir+target`
+	BuildArgs map[string]string // Build args passed to the target, either as `--build-arg K=V` or as `--K=V`
+}
+
+func ParseTargetCall(args []string) (TargetCall, error) {
+	flags, rest := SplitFlags(args)
+	if len(rest) == 0 {
+		return TargetCall{}, errors.New("earthfile: missing target reference")
+	}
+
+	res := TargetCall{Flags: flags, Target: rest[0], BuildArgs: map[string]string{}}
+	flagArgs := lo.Flatten(flags)
+	// The parser may move `--build-arg` flags after the target
+	args = append(flagArgs, rest[1:]...)
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--build-arg" && i+1 < len(args):
+			i++
+			a = args[i]
+		case strings.HasPrefix(a, "--build-arg="):
+			a = strings.TrimPrefix(a, "--build-arg=")
+		case i >= len(flagArgs) && strings.HasPrefix(a, "--"):
+			a = strings.TrimPrefix(a, "--")
+		default:
+			continue // A flag of the command itself, like `--platform`
+		}
+		k, v, _ := strings.Cut(a, "=")
+		res.BuildArgs[k] = v
+	}
+	return res, nil
+}
@@ -1,34 +1,74 @@
 package earthfile
 
 import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
 	"github.com/earthly/earthly/ast/spec"
 )
 
 type BuildCmd struct {
-	Line   string // Earthfile syntax of this command
-	Base   string // Path to the directory in which this command resides
-	Target string // Path to the Earthly target this command builds
+	Line   string     // Earthfile syntax of this command
+	Base   string     // Path to the directory in which this command resides
+	Target string     // Path to the Earthly target this command builds, relative to Base, with ARGs expanded
+	Path   string     // Path to the Earthly target this command builds, e.g. `./services/api+docker`
+	Call   TargetCall // The flags and build args of the command
 }
 
 type buildCmdCollector struct {
 	UnimplementedStmtVisitor
 	ef     *Earthfile
 	builds []BuildCmd
+	err    error // The first error encountered; commands following it are not visited
 }
 
-func CollectBuildCommands(f *Earthfile, t *spec.Target) []BuildCmd {
+// CollectBuildCommands returns the BUILD commands of local targets in the given target.
+func CollectBuildCommands(f *Earthfile, t *spec.Target) ([]BuildCmd, error) {
 	visitor := &buildCmdCollector{ef: f}
 	WalkRecipe(t.Recipe, visitor)
-	return visitor.builds
+	return visitor.builds, visitor.err
 }
 
 func (v *buildCmdCollector) VisitCommand(c spec.Command) {
-	switch c.Name {
-	case "BUILD":
-		v.builds = append(v.builds, BuildCmd{
-			Line:   cmdRepr(c),
-			Base:   v.ef.Dir,
-			Target: c.Args[0],
-		})
+	if v.err != nil || c.Name != "BUILD" {
+		return
+	}
+
+	call, err := ParseTargetCall(c.Args)
+	if err != nil {
+		v.err = fmt.Errorf("%s: %w", v.ef.Path, err)
+		return
+	}
+
+	// Avoid remote targets, and ones that depend on ARGs that can't be resolved statically
+	ref := v.ef.ExpandArgs(call.Target)
+	if !IsLocalTargetRef(ref) || strings.ContainsRune(ref, '$') {
+		return
+	}
+
+	v.builds = append(v.builds, BuildCmd{
+		Line:   cmdRepr(c),
+		Base:   v.ef.Dir,
+		Target: ref,
+		Path:   QualifyTarget(v.ef.Dir, ref),
+		Call:   call,
+	})
+}
+
+// QualifyTarget returns the path of a target referenced relative to the given dir, e.g. `./services`, `../api+docker`
+// -> `./api+docker`. Targets in the current dir are returned as `+target`.
+func QualifyTarget(dir, ref string) string {
+	refDir, name, _ := strings.Cut(ref, "+")
+	if !filepath.IsAbs(refDir) {
+		refDir = filepath.Join(dir, refDir)
+	}
+	switch {
+	case refDir == ".":
+		return "+" + name
+	case filepath.IsAbs(refDir) || strings.HasPrefix(refDir, ".."):
+		return refDir + "+" + name
+	default:
+		return "./" + refDir + "+" + name
 	}
 }
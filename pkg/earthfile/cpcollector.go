@@ -1,7 +1,7 @@
 package earthfile
 
 import (
-	"strings"
+	"fmt"
 
 	"github.com/earthly/earthly/ast/spec"
 )
@@ -21,75 +21,76 @@ type copyCmdCollector struct {
 	UnimplementedStmtVisitor
 	ef   *Earthfile
 	cmds []CopyCmd
+	err  error // The first error encountered; commands following it are not visited
 }
 
 // CollectCopyCommands returns all COPY commands detected in the given Target.
 // For simplicity, it also returns dummy `CopyCmd`s for detected Earthfile dependencies.
 // TODO: separate COPY command collection from target dependency resolution.
-func CollectCopyCommands(f *Earthfile, t *spec.Target) []CopyCmd {
+func CollectCopyCommands(f *Earthfile, t *spec.Target) ([]CopyCmd, error) {
 	visitor := &copyCmdCollector{ef: f}
 	WalkRecipe(t.Recipe, visitor)
-	return visitor.cmds
+	return visitor.cmds, visitor.err
 }
 
 func (v *copyCmdCollector) VisitCommand(c spec.Command) {
+	if v.err != nil {
+		return
+	}
+
 	switch c.Name {
 	case "FROM":
-		v.visitFromCommand(c)
+		v.err = v.visitFromCommand(c)
 	case "COPY":
-		v.visitCopyCommand(c)
+		v.err = v.visitCopyCommand(c)
 	case "BUILD":
 		//log.Printf("[WARNING] %s: skipping BUILD command parsing in copyCmdCollector", v.ef.Dir)
 	}
 }
 
-func (v *copyCmdCollector) visitCopyCommand(c spec.Command) {
-	if c.Name != "COPY" {
-		panic("expected COPY command")
+func (v *copyCmdCollector) visitCopyCommand(c spec.Command) error {
+	args, err := ParseCopyArgs(c.Args)
+	if err != nil {
+		return fmt.Errorf("%s: %w", v.ef.Path, err)
 	}
 
 	res := CopyCmd{
-		Line: cmdRepr(c),
-		File: v.ef,
-	}
-
-	if c.Args[0] == "--dir" {
-		res.DirOpt = true
-		c.Args = c.Args[1:]
-	}
-
-	if len(c.Args) == 2 {
-		res.From, res.To = c.Args[0], c.Args[1]
-		v.cmds = append(v.cmds, res)
-		return
+		Line:   cmdRepr(c),
+		File:   v.ef,
+		To:     args.Dest,
+		DirOpt: HasFlag(args.Flags, "--dir"),
 	}
 
 	// For simplicity, split COPY commands with multiple input paths to multiple commands
-	res.To = c.Args[len(c.Args)-1]
-	for _, from := range c.Args[:len(c.Args)-1] {
+	for _, from := range args.Sources {
 		clone := res
-		clone.From = from
+		clone.From = UnwrapArtifactRef(from)
 		v.cmds = append(v.cmds, clone)
 	}
+	return nil
 }
 
-func (v *copyCmdCollector) visitFromCommand(c spec.Command) {
-	if c.Name != "FROM" {
-		panic("expected FROM command")
+func (v *copyCmdCollector) visitFromCommand(c spec.Command) error {
+	call, err := ParseTargetCall(c.Args)
+	if err != nil {
+		return fmt.Errorf("%s: %w", v.ef.Path, err)
 	}
 
 	// Avoid visiting remote image targets
-	if !strings.ContainsRune(c.Args[0], '+') {
-		return
+	ref := v.ef.ExpandArgs(call.Target)
+	if !IsLocalTargetRef(ref) {
+		return nil
 	}
 
-	ef, t, err := v.ef.Target(v.ef.ExpandArgs(c.Args[0]))
+	ef, t, err := v.ef.Target(ref)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("%s: `%s`: %w", v.ef.Path, cmdRepr(c), err)
 	}
 
 	// Add a fake COPY command for the Earthfile, to trick the pipeline into recognizing it as a dep.
 	v.cmds = append(v.cmds, CopyCmd{Line: SentinelCopyCmdLine, File: v.ef, From: ef.Path})
 
-	v.cmds = append(v.cmds, CollectCopyCommands(ef, t)...)
+	cmds, err := CollectCopyCommands(ef, t)
+	v.cmds = append(v.cmds, cmds...)
+	return err
 }
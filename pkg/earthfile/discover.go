@@ -0,0 +1,35 @@
+package earthfile
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	mapset "github.com/deckarep/golang-set/v2"
+)
+
+var (
+	dirsToSkip = mapset.NewThreadUnsafeSet("testdata", "node_modules", "vendor")
+)
+
+// Discover returns the paths of all Earthfiles in the given dir and its subdirs, skipping hidden, vendored and test
+// data dirs.
+func Discover(root string) ([]string, error) {
+	var res []string
+	err := filepath.WalkDir(root, func(p string, ent fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ent.IsDir() {
+			if p != root && (dirsToSkip.Contains(ent.Name()) || strings.HasPrefix(ent.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ent.Name() == earthfileName {
+			res = append(res, p)
+		}
+		return nil
+	})
+	return res, err
+}
@@ -0,0 +1,73 @@
+package earthfile
+
+import (
+	"fmt"
+	"strings"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/earthly/earthly/ast/spec"
+	"github.com/samber/lo"
+)
+
+type commandCallCollector struct {
+	UnimplementedStmtVisitor
+	ef    *Earthfile
+	calls []string
+	err   error // The first error encountered; commands following it are not visited
+}
+
+// CollectCommandCalls returns the user-defined commands in the repo which the given recipe calls directly via DO, like
+// `+COMMAND` or `../lib+COMMAND`. Calls which depend on ARGs that can't be resolved statically are skipped.
+func CollectCommandCalls(f *Earthfile, recipe spec.Block) ([]string, error) {
+	visitor := &commandCallCollector{ef: f}
+	WalkRecipe(recipe, visitor)
+	return lo.Uniq(visitor.calls), visitor.err
+}
+
+func (v *commandCallCollector) VisitCommand(c spec.Command) {
+	if v.err != nil || c.Name != "DO" {
+		return
+	}
+
+	call, err := ParseTargetCall(c.Args)
+	if err != nil {
+		v.err = fmt.Errorf("%s: %w", v.ef.Path, err)
+		return
+	}
+	ref := v.ef.ExpandArgs(call.Target)
+	if IsLocalTargetRef(ref) && !strings.ContainsRune(ref, '$') {
+		v.calls = append(v.calls, ref)
+	}
+}
+
+// CalledCommandsText returns a canonical representation of the user-defined commands which the given recipe calls,
+// following nested calls, like RecipeText's. Commands are identified by their references as called, so that the
+// representation doesn't depend on where the repo is checked out.
+func CalledCommandsText(f *Earthfile, recipe spec.Block) (string, error) {
+	w := new(strings.Builder)
+	err := writeCalledCommands(w, f, recipe, mapset.NewThreadUnsafeSet[string]())
+	return w.String(), err
+}
+
+func writeCalledCommands(w *strings.Builder, f *Earthfile, recipe spec.Block, seen mapset.Set[string]) error {
+	calls, err := CollectCommandCalls(f, recipe)
+	if err != nil {
+		return err
+	}
+	for _, ref := range calls {
+		cmdEf, cmd, err := f.UserCommand(ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Path, err)
+		}
+		if !seen.Add(cmdEf.Path + "+" + cmd.Name) {
+			continue
+		}
+
+		fmt.Fprintf(w, "%s:\n", ref)
+		writeRecipe(w, cmd.Recipe, 1)
+		if err = writeCalledCommands(w, cmdEf, cmd.Recipe, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
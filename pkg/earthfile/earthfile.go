@@ -23,15 +23,16 @@ type Earthfile struct {
 	Globals   map[string]string
 }
 
-func Parse(path string) (*Earthfile, error) {
+func Parse(path string) (_ *Earthfile, err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			fmt.Printf("ERROR: could not parse %s\n", path)
+			err = fmt.Errorf("earthfile: could not parse %s: %v", path, r)
 		}
 	}()
 
-	// ast.Parse seems to not do anything of importance with ctx, so passing context.Background()
-	a, err := ast.Parse(context.Background(), path, false)
+	// ast.Parse seems to not do anything of importance with ctx, so passing context.Background().
+	// Source mapping is enabled so that statements carry their line numbers.
+	a, err := ast.Parse(context.Background(), path, true)
 	if err != nil {
 		return nil, err
 	}
@@ -50,14 +51,40 @@ func Parse(path string) (*Earthfile, error) {
 	}, nil
 }
 
+// ParseSource parses the given Earthfile source as if it were at the given path; e.g. the source of an Earthfile at
+// another git revision.
+func ParseSource(path string, src []byte) (*Earthfile, error) {
+	// ast.Parse only reads files
+	tmpDir, err := os.MkdirTemp("", "heavenly-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmpPath := filepath.Join(tmpDir, earthfileName)
+	if err = os.WriteFile(tmpPath, src, 0o644); err != nil {
+		return nil, err
+	}
+	ef, err := Parse(tmpPath)
+	if err != nil {
+		return nil, fmt.Errorf("earthfile: could not parse %s: %w", path, err)
+	}
+	ef.Dir, ef.Path = filepath.Dir(path), path
+	return ef, nil
+}
+
 func parseArgs(recipe spec.Block) (map[string]string, error) {
 	res := map[string]string{}
 	for _, s := range recipe {
 		if s.Command != nil && s.Command.Name == "ARG" {
-			if len(s.Command.Args) != 3 || s.Command.Args[1] != "=" {
-				return nil, fmt.Errorf("earthfile: unexpected ARG syntax: %v", s.Command.Args)
+			arg, err := ParseArgDecl(s.Command.Args)
+			if err != nil {
+				return nil, err
+			}
+			// ARGs without a default can't be expanded statically
+			if arg.HasDefault {
+				res[arg.Name] = arg.Default
 			}
-			res[s.Command.Args[0]] = s.Command.Args[2]
 		}
 	}
 	return res, nil
@@ -80,6 +107,7 @@ func (f *Earthfile) ExpandArgs(s string) string {
 	if strings.ContainsRune(s, '$') {
 		// TODO: support target-specific args?
 		for globalName, globalVal := range f.Globals {
+			s = strings.ReplaceAll(s, "${"+globalName+"}", globalVal)
 			s = strings.ReplaceAll(s, "$"+globalName, globalVal)
 		}
 	}
@@ -97,19 +125,45 @@ func (f *Earthfile) Target(path string) (*Earthfile, *spec.Target, error) {
 }
 
 func relTarget(dir, target string) (*Earthfile, *spec.Target, error) {
-	// Look up Earthfile in target dir
+	ef, err := parseIn(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, err := ef.localTarget(target)
+	return ef, t, err
+}
+
+// UserCommand looks up a user-defined command, like Target does a target; e.g. "+COMMAND" or "../lib+COMMAND".
+func (f *Earthfile) UserCommand(path string) (*Earthfile, *spec.UserCommand, error) {
+	ef := f
+	if earthDir, _, _ := strings.Cut(path, "+"); earthDir != "" {
+		var err error
+		if ef, err = parseIn(filepath.Join(f.Dir, earthDir)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	name := path[strings.IndexRune(path, '+')+1:]
+	for i, c := range ef.Spec.UserCommands {
+		if c.Name == name {
+			return ef, &ef.Spec.UserCommands[i], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("earthfile: user-defined command '%s' not found in %s", name, ef.Path)
+}
+
+// parseIn parses the Earthfile in the given dir.
+func parseIn(dir string) (*Earthfile, error) {
 	targetFile := path.Join(dir, earthfileName)
 	if _, err := os.Lstat(targetFile); err != nil {
-		return nil, nil, fmt.Errorf("earthfile: could not stat '%s': %w", targetFile, err.(*os.PathError).Err)
+		return nil, fmt.Errorf("earthfile: could not stat '%s': %w", targetFile, err.(*os.PathError).Err)
 	}
 
 	ef, err := Parse(targetFile)
 	if err != nil {
-		return nil, nil, fmt.Errorf("earthfile: could not parse '%s': %w", targetFile, err)
+		return nil, fmt.Errorf("earthfile: could not parse '%s': %w", targetFile, err)
 	}
-
-	t, err := ef.localTarget(target)
-	return ef, t, err
+	return ef, nil
 }
 
 func (f *Earthfile) localTarget(name string) (*spec.Target, error) {
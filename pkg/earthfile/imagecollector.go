@@ -0,0 +1,76 @@
+package earthfile
+
+import (
+	"strings"
+
+	"github.com/earthly/earthly/ast/spec"
+)
+
+// ImageRef is a FROM command of an external image, as opposed to a target.
+type ImageRef struct {
+	Line   string               // Earthfile syntax of the FROM command
+	File   *Earthfile           // Earthfile where the FROM command resides
+	Target string               // Name of the target where the FROM command resides, or "" for the base recipe
+	Ref    string               // The image reference, with global ARGs expanded; e.g. `golang:1.20`
+	Loc    *spec.SourceLocation // Location of the FROM command
+}
+
+// Name returns the image reference without its tag and digest, as written; e.g. `golang` for `golang:1.20`.
+func (r ImageRef) Name() string {
+	name, _, _ := strings.Cut(r.Ref, "@")
+	// A colon after the last slash separates the tag; others separate a registry port
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	return name
+}
+
+// Tag returns the tag of the image reference, or "" if it has none.
+func (r ImageRef) Tag() string {
+	name, _, _ := strings.Cut(r.Ref, "@")
+	return strings.TrimPrefix(strings.TrimPrefix(name, r.Name()), ":")
+}
+
+// Digest returns the digest of the image reference, like `sha256:...`, or "" if it has none.
+func (r ImageRef) Digest() string {
+	_, digest, _ := strings.Cut(r.Ref, "@")
+	return digest
+}
+
+type imageCollector struct {
+	UnimplementedStmtVisitor
+	ef     *Earthfile
+	target string
+	images []ImageRef
+}
+
+// CollectImages returns the external images which the FROM commands in the given Earthfile, including in its base
+// recipe, are based on.
+func CollectImages(f *Earthfile) []ImageRef {
+	visitor := &imageCollector{ef: f}
+	WalkRecipe(f.Spec.BaseRecipe, visitor)
+	for _, t := range f.Spec.Targets {
+		visitor.target = t.Name
+		WalkRecipe(t.Recipe, visitor)
+	}
+	return visitor.images
+}
+
+func (v *imageCollector) VisitCommand(c spec.Command) {
+	if c.Name != "FROM" {
+		return
+	}
+
+	_, rest := SplitFlags(c.Args)
+	if len(rest) == 0 || strings.ContainsRune(rest[0], '+') {
+		return // A target, rather than an image
+	}
+
+	v.images = append(v.images, ImageRef{
+		Line:   cmdRepr(c),
+		File:   v.ef,
+		Target: v.target,
+		Ref:    v.ef.ExpandArgs(rest[0]),
+		Loc:    c.SourceLocation,
+	})
+}
@@ -0,0 +1,77 @@
+package earthfile
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/earthly/earthly/ast/spec"
+	"github.com/samber/lo"
+)
+
+// RecipeText returns a canonical representation of the given recipe, which changes only along with its commands and
+// the blocks they're nested in; not with formatting, comments or source positions.
+func RecipeText(recipe spec.Block) string {
+	w := new(strings.Builder)
+	writeRecipe(w, recipe, 0)
+	return w.String()
+}
+
+// HeaderText returns a canonical representation of the VERSION and IMPORT commands of the given Earthfile, which all of
+// its targets and user-defined commands depend on.
+func HeaderText(f *Earthfile) string {
+	var header spec.Block
+	if f.Spec.Version != nil {
+		header = append(header, spec.Statement{Command: &spec.Command{Name: "VERSION", Args: f.Spec.Version.Args}})
+	}
+	header = append(header, lo.Filter(f.Spec.BaseRecipe, func(s spec.Statement, _ int) bool {
+		return s.Command != nil && s.Command.Name == "IMPORT"
+	})...)
+	return RecipeText(header)
+}
+
+func writeRecipe(w *strings.Builder, recipe spec.Block, depth int) {
+	line := func(name string, args []string, execMode bool) {
+		w.WriteString(strings.Repeat("\t", depth))
+		w.WriteString(name)
+		// JSON-encoded, so that e.g. `RUN a b` and `RUN "a b"` differ
+		encoded, _ := json.Marshal(args)
+		w.WriteRune(' ')
+		w.Write(encoded)
+		if execMode {
+			w.WriteString(" exec")
+		}
+		w.WriteRune('\n')
+	}
+	body := func(b spec.Block) { writeRecipe(w, b, depth+1) }
+
+	for _, s := range recipe {
+		switch {
+		case s.Command != nil:
+			line(s.Command.Name, s.Command.Args, s.Command.ExecMode)
+		case s.With != nil:
+			line("WITH "+s.With.Command.Name, s.With.Command.Args, s.With.Command.ExecMode)
+			body(s.With.Body)
+			line("END", nil, false)
+		case s.If != nil:
+			line("IF", s.If.Expression, s.If.ExecMode)
+			body(s.If.IfBody)
+			for _, b := range s.If.ElseIf {
+				line("ELSE IF", b.Expression, b.ExecMode)
+				body(b.Body)
+			}
+			if s.If.ElseBody != nil {
+				line("ELSE", nil, false)
+				body(*s.If.ElseBody)
+			}
+			line("END", nil, false)
+		case s.For != nil:
+			line("FOR", s.For.Args, false)
+			body(s.For.Body)
+			line("END", nil, false)
+		case s.Wait != nil:
+			line("WAIT", s.Wait.Args, false)
+			body(s.Wait.Body)
+			line("END", nil, false)
+		}
+	}
+}
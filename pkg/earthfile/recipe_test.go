@@ -0,0 +1,94 @@
+package earthfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseTestSource(t *testing.T, src string) *Earthfile {
+	t.Helper()
+	ef, err := ParseSource(filepath.Join(t.TempDir(), earthfileName), []byte(src))
+	require.NoError(t, err)
+	return ef
+}
+
+func TestRecipeText(t *testing.T) {
+	text := func(recipe string) string {
+		ef := parseTestSource(t, "VERSION 0.6\n\nt:\n"+recipe)
+		return RecipeText(ef.Spec.Targets[0].Recipe)
+	}
+
+	base := text("    RUN echo a\n    IF true\n        RUN echo b\n    END\n")
+	// Formatting and comments don't matter
+	assert.Equal(t, base, text("  # comment\n  RUN    echo a\n\n  IF true\n    RUN echo b\n  END\n"))
+	// Quoting, nesting and exec mode do
+	assert.NotEqual(t, base, text("    RUN \"echo a\"\n    IF true\n        RUN echo b\n    END\n"))
+	assert.NotEqual(t, base, text("    RUN echo a\n    IF true\n    END\n    RUN echo b\n"))
+	assert.NotEqual(t, base, text("    RUN [\"echo\", \"a\"]\n    IF true\n        RUN echo b\n    END\n"))
+}
+
+func TestCollectTargetRefs(t *testing.T) {
+	ef := parseTestSource(t, "VERSION 0.6\nARG LIB=../lib\n\nt:\n"+
+		"    FROM --platform=linux/amd64 +deps\n"+
+		"    BUILD $LIB+build --VERSION=1\n"+
+		"    COPY (+gen/out --flag=x) ./gen/\n"+
+		"    COPY ../lib+src/*.go src.txt ./\n"+
+		"    COPY $DYNAMIC+src/x .\n"+
+		"    BUILD +deps\n"+
+		"    FROM alpine\n"+
+		"    BUILD github.com/acme/repo+target\n")
+
+	refs, err := CollectTargetRefs(ef, &ef.Spec.Targets[0])
+	require.NoError(t, err)
+	assert.Equal(t, []TargetRef{
+		{"FROM", "+deps"},
+		{"BUILD", "../lib+build"},
+		{"COPY", "+gen"},
+		{"COPY", "../lib+src"},
+		{"BUILD", "+deps"},
+	}, refs)
+}
+
+func TestCalledCommandsText(t *testing.T) {
+	dir := t.TempDir()
+	write := func(p, src string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, p)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, p), []byte(src), 0o644))
+	}
+	text := func(libMsg string) string {
+		write("lib/Earthfile", "VERSION 0.6\n\nSHARED:\n    COMMAND\n    RUN echo "+libMsg+"\n")
+		ef, err := Parse(filepath.Join(dir, earthfileName))
+		require.NoError(t, err)
+		res, err := CalledCommandsText(ef, ef.Spec.Targets[0].Recipe)
+		require.NoError(t, err)
+		return res
+	}
+	write(earthfileName, "VERSION 0.6\nARG LIB=./lib\n\nt:\n    DO +LOCAL\n    DO $LIB+SHARED --X=1\n    RUN true\n\n"+
+		"LOCAL:\n    COMMAND\n    DO +LOCAL2\n\nLOCAL2:\n    COMMAND\n    RUN echo local\n")
+
+	assert.Equal(t, `+LOCAL:
+	COMMAND []
+	DO ["+LOCAL2"]
++LOCAL2:
+	COMMAND []
+	RUN ["echo","local"]
+./lib+SHARED:
+	COMMAND []
+	RUN ["echo","a"]
+`, text("a"))
+	// Commands in other Earthfiles count too
+	assert.NotEqual(t, text("a"), text("b"))
+
+	ef := parseTestSource(t, "VERSION 0.6\n\nt:\n    DO +MISSING\n")
+	_, err := CalledCommandsText(ef, ef.Spec.Targets[0].Recipe)
+	assert.ErrorContains(t, err, "'MISSING' not found")
+}
+
+func TestHeaderText(t *testing.T) {
+	ef := parseTestSource(t, "VERSION 0.6\nFROM alpine\nARG X=1\n\nt:\n    RUN true\n")
+	assert.Equal(t, "VERSION [\"0.6\"]\n", HeaderText(ef))
+}
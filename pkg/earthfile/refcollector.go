@@ -0,0 +1,66 @@
+package earthfile
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/earthly/earthly/ast/spec"
+	"github.com/samber/lo"
+)
+
+// TargetRef is a reference to a local target in a command.
+type TargetRef struct {
+	Cmd    string // FROM, BUILD or COPY
+	Target string // With ARGs expanded, e.g. `+deps` or `../lib+src`
+}
+
+type targetRefCollector struct {
+	UnimplementedStmtVisitor
+	ef   *Earthfile
+	refs []TargetRef
+	err  error // The first error encountered; commands following it are not visited
+}
+
+// CollectTargetRefs returns the local targets which the given target references directly in FROM, BUILD and COPY
+// commands. References which depend on ARGs that can't be resolved statically are skipped.
+func CollectTargetRefs(f *Earthfile, t *spec.Target) ([]TargetRef, error) {
+	visitor := &targetRefCollector{ef: f}
+	WalkRecipe(t.Recipe, visitor)
+	return lo.Uniq(visitor.refs), visitor.err
+}
+
+func (v *targetRefCollector) VisitCommand(c spec.Command) {
+	if v.err != nil {
+		return
+	}
+
+	var refs []string
+	switch c.Name {
+	case "FROM", "BUILD":
+		call, err := ParseTargetCall(c.Args)
+		if err != nil {
+			v.err = fmt.Errorf("%s: %w", v.ef.Path, err)
+			return
+		}
+		refs = []string{call.Target}
+	case "COPY":
+		args, err := ParseCopyArgs(c.Args)
+		if err != nil {
+			v.err = fmt.Errorf("%s: %w", v.ef.Path, err)
+			return
+		}
+		for _, src := range args.Sources {
+			if src = UnwrapArtifactRef(src); strings.ContainsRune(src, '+') {
+				target, _ := SplitArtifactRef(src)
+				refs = append(refs, target)
+			}
+		}
+	}
+
+	for _, ref := range refs {
+		ref = v.ef.ExpandArgs(ref)
+		if IsLocalTargetRef(ref) && !strings.ContainsRune(ref, '$') {
+			v.refs = append(v.refs, TargetRef{Cmd: c.Name, Target: ref})
+		}
+	}
+}
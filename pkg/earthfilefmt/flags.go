@@ -0,0 +1,35 @@
+package earthfilefmt
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/dorfire/heavenly/pkg/earthfile"
+)
+
+// orderFlags moves the leading flags of args that appear in order to the front, in that order.
+// Other flags keep their relative order and follow them.
+func orderFlags(args []string, order []string) []string {
+	if len(order) == 0 {
+		return args
+	}
+
+	rank := func(flag []string) int {
+		name, _, _ := strings.Cut(flag[0], "=")
+		for i, o := range order {
+			if o == name {
+				return i
+			}
+		}
+		return len(order)
+	}
+
+	flags, rest := earthfile.SplitFlags(args)
+	sort.SliceStable(flags, func(i, j int) bool { return rank(flags[i]) < rank(flags[j]) })
+
+	res := make([]string, 0, len(args))
+	for _, f := range flags {
+		res = append(res, f...)
+	}
+	return append(res, rest...)
+}
@@ -2,31 +2,59 @@ package earthfilefmt
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/earthly/earthly/ast/spec"
+	"github.com/samber/lo"
 )
 
 const (
-	indentationPrefix = "    " // four spaces
+	// SelfCopyCmd copies the source files of the Earthfile's own directory
+	SelfCopyCmd = "COPY --dir +src/* ."
 )
 
+// Formatter formats Earthfiles according to a set of Options.
+type Formatter struct {
+	opts   Options
+	indent string
+}
+
+func New(opts Options) *Formatter {
+	return &Formatter{opts: opts, indent: opts.indentation()}
+}
+
+// Format formats an Earthfile using DefaultOptions.
 func Format(ef spec.Earthfile) string {
+	return New(DefaultOptions()).Format(ef, nil)
+}
+
+// Format formats the given Earthfile.
+// src is the original content of the Earthfile; it is only used to detect blank lines, and may be nil.
+func (f *Formatter) Format(ef spec.Earthfile, src []byte) string {
 	w := new(strBuilder)
+	lines := strings.Split(string(src), "\n")
 
 	w.Write(FormatCmd("VERSION", ef.Version.Args))
 	w.WriteNl()
 	w.WriteNl()
 
-	formatRecipe(w, 0, ef.BaseRecipe)
+	f.formatRecipe(w, 0, ef.BaseRecipe, lines)
 	w.WriteNl()
 
-	for _, r := range ef.Targets {
+	for i, r := range ef.Targets {
 		w.Write(r.Name)
 		w.WriteRune(':')
 		w.WriteNl()
-		formatRecipe(w, 1, r.Recipe)
-		w.WriteNl()
+		f.formatRecipe(w, 1, r.Recipe, lines)
+		// The last target is followed by a single blank line, however many separate targets
+		blankLines := f.opts.BlankLinesBetweenTargets
+		if i == len(ef.Targets)-1 {
+			blankLines = lo.Min([]int{blankLines, 1})
+		}
+		for j := 0; j < blankLines; j++ {
+			w.WriteNl()
+		}
 	}
 
 	// TODO: also format ef.UserCommands
@@ -38,21 +66,83 @@ func FormatCmd(cmd string, args []string) string {
 	return fmt.Sprintf("%s %s", cmd, FormatArgs(args))
 }
 
+// FormatCmd formats a single command, without indentation.
+func (f *Formatter) FormatCmd(cmd string, args []string) string {
+	if !f.opts.NormalizeArgs {
+		return FormatCmd(cmd, orderFlags(args, f.opts.FlagOrder))
+	}
+	return fmt.Sprintf("%s %s", cmd, joinArgs(cmd, orderFlags(normalizeArgs(cmd, args), f.opts.FlagOrder)))
+}
+
+// Indent returns the prefix of a line at the given indentation level.
+func (f *Formatter) Indent(level int) string {
+	return strings.Repeat(f.indent, level)
+}
+
 // TODO: recurse
 // TODO: support '#' comments
-func formatRecipe(w *strBuilder, indent int, r spec.Block) {
-	for _, c := range r {
-		if c.Command == nil {
+func (f *Formatter) formatRecipe(w *strBuilder, indent int, r spec.Block, lines []string) {
+	for i := 0; i < len(r); {
+		if r[i].Command == nil {
 			panic("unimplemented: non-command tokens in recipe")
 		}
 
-		if indent != 0 {
-			w.Write(strings.Repeat(indentationPrefix, indent))
+		if i > 0 && f.opts.KeepBlankLines && blankLineBetween(lines, r[i-1], r[i]) {
+			w.WriteNl()
 		}
 
-		w.Write(FormatCmd(c.Command.Name, c.Command.Args))
-		w.WriteNl()
+		// Contiguous COPY commands are formatted as a run, in which duplicates are dropped
+		j := i + 1
+		if r[i].Command.Name == "COPY" {
+			for j < len(r) && isCopyStmt(r[j]) && !(f.opts.KeepBlankLines && blankLineBetween(lines, r[j-1], r[j])) {
+				j++
+			}
+		}
+
+		cmdLines := lo.Uniq(lo.Map(r[i:j], func(s spec.Statement, _ int) string {
+			return f.FormatCmd(s.Command.Name, s.Command.Args)
+		}))
+		if j-i > 1 && f.opts.SortCopies {
+			SortCopyLines(cmdLines)
+		}
+
+		for _, l := range cmdLines {
+			w.Write(f.Indent(indent))
+			w.Write(l)
+			w.WriteNl()
+		}
+		i = j
+	}
+}
+
+func isCopyStmt(s spec.Statement) bool {
+	return s.Command != nil && s.Command.Name == "COPY"
+}
+
+// SortCopyLines sorts formatted COPY commands lexicographically, except for the "COPY --dir +src/* ." command, which
+// is moved first.
+func SortCopyLines(cmdLines []string) {
+	sort.SliceStable(cmdLines, func(i, j int) bool {
+		if iSelf, jSelf := cmdLines[i] == SelfCopyCmd, cmdLines[j] == SelfCopyCmd; iSelf != jSelf {
+			return iSelf
+		}
+		return cmdLines[i] < cmdLines[j]
+	})
+}
+
+// blankLineBetween returns whether there's at least one blank line between two consecutive statements.
+// Lines holding '#' comments do not count as blank.
+func blankLineBetween(lines []string, prev, next spec.Statement) bool {
+	if prev.SourceLocation == nil || next.SourceLocation == nil {
+		return false
+	}
+	// SourceLocation lines are 1-based, so lines[EndLine] is the line following prev
+	for l := prev.SourceLocation.EndLine; l < next.SourceLocation.StartLine-1 && l < len(lines); l++ {
+		if strings.TrimSpace(lines[l]) == "" {
+			return true
+		}
 	}
+	return false
 }
 
 func FormatArgs(args []string) string {
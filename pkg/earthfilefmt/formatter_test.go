@@ -0,0 +1,111 @@
+package earthfilefmt_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/earthly/earthly/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dorfire/heavenly/pkg/earthfilefmt"
+)
+
+func TestFormat(t *testing.T) {
+	src, err := os.ReadFile("testdata/Earthfile")
+	require.NoError(t, err)
+	ef, err := ast.Parse(context.Background(), "testdata/Earthfile", true)
+	require.NoError(t, err)
+
+	assert.Equal(t, `VERSION 0.6
+
+FROM golang:1.20
+
+build:
+    COPY --keep-ts --dir src/ .
+    RUN go build ./...
+
+test:
+    FROM +build
+    RUN go test ./...
+
+`, earthfilefmt.Format(ef))
+
+	opts := earthfilefmt.Options{
+		UseTabs:                  true,
+		BlankLinesBetweenTargets: 2,
+		KeepBlankLines:           true,
+		FlagOrder:                []string{"--dir"},
+	}
+	assert.Equal(t, `VERSION 0.6
+
+FROM golang:1.20
+
+build:
+	COPY --dir --keep-ts src/ .
+
+	RUN go build ./...
+
+
+test:
+	FROM +build
+	RUN go test ./...
+
+`, earthfilefmt.New(opts).Format(ef, src))
+}
+
+func TestFormatCopyRuns(t *testing.T) {
+	ef, err := ast.Parse(context.Background(), "testdata/copies/Earthfile", true)
+	require.NoError(t, err)
+
+	assert.Equal(t, `VERSION 0.6
+
+
+build:
+    COPY go.mod go.sum .
+    COPY --dir $TOP/libs/+src/b/* libs/b/
+    COPY --dir +src/* .
+    COPY --dir $TOP/libs/+src/a/* libs/a/
+    RUN go mod download
+    COPY go.mod go.sum .
+
+`, earthfilefmt.Format(ef))
+
+	opts := earthfilefmt.DefaultOptions()
+	opts.SortCopies = true
+	assert.Equal(t, `VERSION 0.6
+
+
+build:
+    COPY --dir +src/* .
+    COPY --dir $TOP/libs/+src/a/* libs/a/
+    COPY --dir $TOP/libs/+src/b/* libs/b/
+    COPY go.mod go.sum .
+    RUN go mod download
+    COPY go.mod go.sum .
+
+`, earthfilefmt.New(opts).Format(ef, nil))
+}
+
+func TestFormatNormalizeArgs(t *testing.T) {
+	ef, err := ast.Parse(context.Background(), "testdata/normalize/Earthfile", true)
+	require.NoError(t, err)
+
+	opts := earthfilefmt.DefaultOptions()
+	opts.NormalizeArgs = true
+	assert.Equal(t, `VERSION 0.6
+
+ARG GO_VERSION=1.20
+
+build:
+    FROM +base --GO_VERSION=1.21
+    COPY --keep-ts --dir src/ out/ ./
+    COPY (+artifact/bin --dir=x) ./
+    RUN test "$GO_VERSION" = 1.20
+    RUN ls "--" "-la"
+    COPY "--x" ./
+    BUILD --platform=linux/amd64 +image --TAG=latest --MODE=release
+
+`, earthfilefmt.New(opts).Format(ef, nil))
+}
@@ -0,0 +1,102 @@
+package earthfilefmt
+
+import (
+	"regexp"
+	"strings"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/samber/lo"
+
+	"github.com/dorfire/heavenly/pkg/earthfile"
+)
+
+var (
+	// Boolean COPY flags, which Earthly accepts anywhere in the command but are canonically written before sources
+	copyBoolFlags = mapset.NewThreadUnsafeSet("--dir", "--keep-ts", "--keep-own", "--if-exists", "--symlink-no-follow")
+	// Commands whose args are parsed as KEY=value pairs
+	keyValueCmds = mapset.NewThreadUnsafeSet("ARG", "ENV", "LABEL", "LET", "SET")
+	// Quoted args made of these characters only mean the same without their quotes
+	needlesslyQuotedRe = regexp.MustCompile(`^(["'])([A-Za-z0-9_./:@%+,=-]+)(["'])$`)
+)
+
+// normalizeArgs canonicalizes the spelling of a command's args:
+//   - boolean COPY flags such as --dir are moved before the sources
+//   - `BUILD/FROM --build-arg X=Y +target` is rewritten as the equivalent `BUILD/FROM +target --X=Y`
+//   - quotes are removed from args that don't need them
+func normalizeArgs(cmd string, args []string) []string {
+	args = lo.Map(args, func(a string, _ int) string { return unquote(a) })
+
+	switch cmd {
+	case "COPY":
+		args = hoistCopyFlags(args)
+	case "BUILD", "FROM":
+		args = inlineBuildArgs(args)
+	}
+	return args
+}
+
+// joinArgs joins args with spaces. The '=' separator is only glued to its surroundings in commands that declare
+// KEY=value pairs, such as ARG; elsewhere (e.g. `RUN test "$X" = y`) it is a standalone word.
+func joinArgs(cmd string, args []string) string {
+	if keyValueCmds.Contains(cmd) {
+		return FormatArgs(args)
+	}
+	return strings.Join(args, " ")
+}
+
+func unquote(a string) string {
+	if flag, val, isFlag := strings.Cut(a, "="); isFlag && strings.HasPrefix(flag, "--") {
+		return flag + "=" + unquote(val)
+	}
+	// Unquoted, an arg starting with `-` would be parsed as a flag
+	if m := needlesslyQuotedRe.FindStringSubmatch(a); m != nil && m[1] == m[3] && !strings.HasPrefix(m[2], "-") {
+		return m[2]
+	}
+	return a
+}
+
+func hoistCopyFlags(args []string) []string {
+	flags, rest := earthfile.SplitFlags(args)
+
+	var hoisted, positional []string
+	parenDepth := 0
+	for _, a := range rest {
+		// Flags within parentheses, as in `COPY (+target/artifact --dir=x) .`, belong to the artifact reference
+		if parenDepth == 0 && copyBoolFlags.Contains(a) {
+			hoisted = append(hoisted, a)
+			continue
+		}
+		parenDepth += strings.Count(a, "(") - strings.Count(a, ")")
+		positional = append(positional, a)
+	}
+
+	res := lo.Flatten(flags)
+	res = append(res, hoisted...)
+	return append(res, positional...)
+}
+
+func inlineBuildArgs(args []string) []string {
+	flags, rest := earthfile.SplitFlags(args)
+	if len(rest) == 0 {
+		return args
+	}
+
+	var keptFlags [][]string
+	var buildArgs []string
+	for _, f := range flags {
+		name, val, _ := strings.Cut(f[0], "=")
+		if len(f) == 2 {
+			val = f[1]
+		}
+		// `--build-arg X` (with no value) passes the value of a local ARG X, so it isn't rewritten
+		if name == "--build-arg" && strings.Contains(val, "=") {
+			buildArgs = append(buildArgs, "--"+val)
+			continue
+		}
+		keptFlags = append(keptFlags, f)
+	}
+
+	res := lo.Flatten(keptFlags)
+	res = append(res, rest...)
+	return append(res, buildArgs...)
+}
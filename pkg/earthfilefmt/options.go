@@ -0,0 +1,46 @@
+package earthfilefmt
+
+import (
+	"errors"
+	"strings"
+)
+
+// Options controls the output of a Formatter.
+type Options struct {
+	IndentWidth              int      `yaml:"indent_width"`                // Spaces per indentation level; ignored if UseTabs is set
+	UseTabs                  bool     `yaml:"use_tabs"`                    // Indent with a single tab per level
+	BlankLinesBetweenTargets int      `yaml:"blank_lines_between_targets"` // Blank lines written after each target
+	KeepBlankLines           bool     `yaml:"keep_blank_lines"`            // Keep blank-line groups inside recipes (collapsed to one line)
+	FlagOrder                []string `yaml:"flag_order"`                  // Leading command flags to hoist, in this order
+	SortCopies               bool     `yaml:"sort_copies"`                 // Sort contiguous runs of COPY commands
+	NormalizeArgs            bool     `yaml:"normalize_args"`              // Canonicalize flag spelling and quoting
+}
+
+func DefaultOptions() Options {
+	return Options{
+		IndentWidth:              4,
+		BlankLinesBetweenTargets: 1,
+	}
+}
+
+func (o Options) Validate() error {
+	if !o.UseTabs && o.IndentWidth < 1 {
+		return errors.New("earthfilefmt: indent_width must be positive")
+	}
+	if o.BlankLinesBetweenTargets < 0 {
+		return errors.New("earthfilefmt: blank_lines_between_targets must not be negative")
+	}
+	for _, f := range o.FlagOrder {
+		if !strings.HasPrefix(f, "-") {
+			return errors.New("earthfilefmt: flag_order entries must start with '-': " + f)
+		}
+	}
+	return nil
+}
+
+func (o Options) indentation() string {
+	if o.UseTabs {
+		return "\t"
+	}
+	return strings.Repeat(" ", o.IndentWidth)
+}
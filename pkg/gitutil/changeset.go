@@ -0,0 +1,220 @@
+package gitutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/samber/lo"
+
+	"github.com/dorfire/heavenly/pkg/repopath"
+)
+
+var (
+	diffTreeOptions = &object.DiffTreeOptions{
+		DetectRenames: true,
+		RenameScore:   50, // Like git's default --find-renames threshold
+		RenameLimit:   1000,
+	}
+	emptyBlobHash = plumbing.ComputeHash(plumbing.BlobObject, nil)
+)
+
+// ChangeSet is the set of files which differ between two states of a repo.
+type ChangeSet struct {
+	Added, Modified, Deleted mapset.Set[repopath.Path]
+	// Renamed maps the old path of each renamed file, possibly with modifications, to its new path.
+	Renamed map[repopath.Path]repopath.Path
+	// Copied maps the path of each added file that's an exact copy of a file in the base revision to the copy's source.
+	Copied map[repopath.Path]repopath.Path
+	// Base is the commit the changes are relative to.
+	Base plumbing.Hash
+	// Target is the commit the changes lead to; for uncommitted changes, HEAD, which they are on top of.
+	Target plumbing.Hash
+	// Uncommitted is DiffStaged or DiffWorktree if the changes lead to the index or working tree rather than Target.
+	Uncommitted DiffTarget
+}
+
+func newChangeSet() ChangeSet {
+	return ChangeSet{
+		Added:    mapset.NewSet[repopath.Path](),
+		Modified: mapset.NewSet[repopath.Path](),
+		Deleted:  mapset.NewSet[repopath.Path](),
+		Renamed:  map[repopath.Path]repopath.Path{},
+		Copied:   map[repopath.Path]repopath.Path{},
+	}
+}
+
+// All returns the paths of all changed files, including both the old and new paths of renamed files.
+func (s ChangeSet) All() mapset.Set[repopath.Path] {
+	res := s.Added.Union(s.Modified).Union(s.Deleted)
+	for from, to := range s.Renamed {
+		res.Add(from)
+		res.Add(to)
+	}
+	for to := range s.Copied {
+		res.Add(to)
+	}
+	return res
+}
+
+func (s ChangeSet) MarshalJSON() ([]byte, error) {
+	sorted := func(set mapset.Set[repopath.Path]) []string {
+		if set == nil {
+			return []string{}
+		}
+		return repopath.Strings(set.ToSlice())
+	}
+	return json.Marshal(struct {
+		Added    []string                        `json:"added"`
+		Modified []string                        `json:"modified"`
+		Deleted  []string                        `json:"deleted"`
+		Renamed  map[repopath.Path]repopath.Path `json:"renamed"`
+		Copied   map[repopath.Path]repopath.Path `json:"copied"`
+		Base     string                          `json:"base,omitempty"`
+		Target   string                          `json:"target,omitempty"`
+	}{
+		sorted(s.Added), sorted(s.Modified), sorted(s.Deleted),
+		lo.Ternary(s.Renamed == nil, map[repopath.Path]repopath.Path{}, s.Renamed),
+		lo.Ternary(s.Copied == nil, map[repopath.Path]repopath.Path{}, s.Copied),
+		lo.Ternary(s.Base.IsZero(), "", s.Base.String()),
+		lo.Ternary(s.Target.IsZero(), "", s.Target.String()),
+	})
+}
+
+// detectExactRenamesAndCopies moves added files with the same contents as a deleted file to Renamed, and ones with
+// the same contents as another file in the base tree to Copied. targetHash returns the blob hash of an added file.
+func (s ChangeSet) detectExactRenamesAndCopies(
+	baseTree *object.Tree, targetHash func(p repopath.Path) (plumbing.Hash, error),
+) error {
+	if s.Added.Cardinality() == 0 {
+		return nil
+	}
+
+	// Base file paths by content hash, preferring deleted files, which make renames
+	var sources map[plumbing.Hash]repopath.Path
+	deleted := map[plumbing.Hash]repopath.Path{}
+	for _, p := range s.Deleted.ToSlice() {
+		if e, err := baseTree.FindEntry(string(p)); err == nil {
+			deleted[e.Hash] = p
+		}
+	}
+
+	added := s.Added.ToSlice()
+	sort.Slice(added, func(i, j int) bool { return added[i] < added[j] })
+	for _, p := range added {
+		h, err := targetHash(p)
+		if err != nil {
+			return err
+		}
+		if h == emptyBlobHash {
+			continue // Empty files are alike, but hardly copies
+		}
+
+		if from, ok := deleted[h]; ok {
+			delete(deleted, h)
+			s.Deleted.Remove(from)
+			s.Added.Remove(p)
+			s.Renamed[from] = p
+			continue
+		}
+
+		if sources == nil {
+			if sources, err = blobPaths(baseTree); err != nil {
+				return err
+			}
+		}
+		if from, ok := sources[h]; ok {
+			s.Added.Remove(p)
+			s.Copied[p] = from
+		}
+	}
+	return nil
+}
+
+// detectSimilarRenames moves added files whose contents are similar enough to a deleted file's, per the rename
+// score of diffTreeOptions, to Renamed, like go-git does between trees. baseFile and targetFile return the contents
+// of a deleted and of an added file.
+func (s ChangeSet) detectSimilarRenames(baseFile, targetFile func(p repopath.Path) ([]byte, error)) error {
+	deleted, added := sortedPaths(s.Deleted), sortedPaths(s.Added)
+	if len(deleted) == 0 || len(added) == 0 ||
+		uint(len(deleted)) > diffTreeOptions.RenameLimit || uint(len(added)) > diffTreeOptions.RenameLimit {
+		return nil
+	}
+
+	baseContents := make([][]byte, len(deleted))
+	for i, p := range deleted {
+		var err error
+		if baseContents[i], err = baseFile(p); err != nil {
+			return err
+		}
+	}
+
+	type rename struct {
+		from, to repopath.Path
+		score    int
+	}
+	var candidates []rename
+	for _, to := range added {
+		content, err := targetFile(to)
+		if err != nil {
+			return err
+		}
+		for i, from := range deleted {
+			if score := similarity(baseContents[i], content); score >= int(diffTreeOptions.RenameScore) {
+				candidates = append(candidates, rename{from, to, score})
+			}
+		}
+	}
+
+	// The most similar files are paired first
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+	for _, r := range candidates {
+		if s.Deleted.Contains(r.from) && s.Added.Contains(r.to) {
+			s.Deleted.Remove(r.from)
+			s.Added.Remove(r.to)
+			s.Renamed[r.from] = r.to
+		}
+	}
+	return nil
+}
+
+// similarity returns the percentage of the bytes of the larger of the given file contents which are in lines both
+// have, an estimate like git's of how similar they are. Empty files aren't similar to any.
+func similarity(a, b []byte) int {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	lines := map[string]int{}
+	for _, l := range bytes.SplitAfter(a, []byte("\n")) {
+		lines[string(l)]++
+	}
+	common := 0
+	for _, l := range bytes.SplitAfter(b, []byte("\n")) {
+		if lines[string(l)] > 0 {
+			lines[string(l)]--
+			common += len(l)
+		}
+	}
+	return common * 100 / lo.Max([]int{len(a), len(b)})
+}
+
+func sortedPaths(set mapset.Set[repopath.Path]) []repopath.Path {
+	res := set.ToSlice()
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
+
+// blobPaths returns the path of the first file in the given tree with each content hash.
+func blobPaths(tree *object.Tree) (map[plumbing.Hash]repopath.Path, error) {
+	res := map[plumbing.Hash]repopath.Path{}
+	err := tree.Files().ForEach(func(f *object.File) error {
+		if _, ok := res[f.Hash]; !ok {
+			res[f.Hash] = repopath.Path(f.Name)
+		}
+		return nil
+	})
+	return res, err
+}
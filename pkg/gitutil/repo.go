@@ -2,16 +2,16 @@ package gitutil
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 
-	mapset "github.com/deckarep/golang-set/v2"
 	git "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
-)
+	"github.com/go-git/go-git/v5/plumbing/object"
 
-type ChangeSet struct {
-	Added, Modified, Deleted mapset.Set[string]
-}
+	"github.com/dorfire/heavenly/pkg/repopath"
+)
 
 func OpenRepo(somePathInRepo string) (*git.Repository, error) {
 	repo, err := git.PlainOpenWithOptions(somePathInRepo, &git.PlainOpenOptions{DetectDotGit: true})
@@ -21,46 +21,143 @@ func OpenRepo(somePathInRepo string) (*git.Repository, error) {
 	return repo, nil
 }
 
-func FilesChanged(ctx context.Context, repo *git.Repository, fromRef string, toCommit string) (ChangeSet, error) {
-	baseRef, err := repo.Reference(plumbing.ReferenceName(fromRef), true)
+// RootDir returns the root directory of the given repo's worktree.
+func RootDir(repo *git.Repository) (string, error) {
+	wt, err := repo.Worktree()
 	if err != nil {
-		return ChangeSet{}, fmt.Errorf("base reference '%s' not found: %w", fromRef, err)
+		return "", fmt.Errorf("could not open git worktree: %w", err)
 	}
+	return wt.Filesystem.Root(), nil
+}
+
+type DiffOptions struct {
+	// MergeBase diffs toRev against the merge base of both revisions rather than fromRev, like `git diff A...B`, so
+	// that changes which landed on fromRev after toRev branched off of it are excluded.
+	MergeBase bool
+	// Target is what to diff against fromRev. Unless it's DiffCommit, toRev must be HEAD.
+	Target DiffTarget
+}
 
-	baseCommitObj, err := repo.CommitObject(baseRef.Hash())
+// FilesChanged returns the files changed between the given revisions, which may be any revision expressions
+// ResolveRevision accepts; or between fromRev and the index or working tree, per opts.Target.
+func FilesChanged(
+	ctx context.Context, repo *git.Repository, fromRev string, toRev string, opts DiffOptions,
+) (ChangeSet, error) {
+	baseCommitObj, err := resolveCommit(repo, fromRev)
 	if err != nil {
-		return ChangeSet{}, fmt.Errorf("base commit '%s' not found: %w", baseRef.Hash(), err)
+		return ChangeSet{}, fmt.Errorf("base revision: %w", err)
 	}
 
-	toCommitObj, err := repo.CommitObject(plumbing.NewHash(toCommit))
+	toCommitObj, err := resolveCommit(repo, toRev)
 	if err != nil {
-		return ChangeSet{}, fmt.Errorf("target commit '%s' not found: %w", toCommit, err)
+		return ChangeSet{}, fmt.Errorf("target revision: %w", err)
 	}
 
-	diff, err := baseCommitObj.PatchContext(ctx, toCommitObj)
+	if opts.MergeBase {
+		if baseCommitObj, err = mergeBase(baseCommitObj, toCommitObj); err != nil {
+			return ChangeSet{}, fmt.Errorf("%s...%s: %w", fromRev, toRev, err)
+		}
+	}
+
+	var res ChangeSet
+	if opts.Target == DiffCommit {
+		res, err = diffCommits(ctx, baseCommitObj, toCommitObj)
+	} else {
+		var head *object.Commit
+		if head, err = resolveCommit(repo, "HEAD"); err != nil {
+			return ChangeSet{}, err
+		}
+		if head.Hash != toCommitObj.Hash {
+			return ChangeSet{}, fmt.Errorf("target revision %s isn't HEAD, which uncommitted changes are on top of",
+				toRev)
+		}
+		res, err = uncommittedChanges(ctx, repo, baseCommitObj, head, opts.Target)
+	}
 	if err != nil {
 		return ChangeSet{}, err
 	}
+	res.Base, res.Target, res.Uncommitted = baseCommitObj.Hash, toCommitObj.Hash, opts.Target
+	return res, nil
+}
+
+// FileAt returns the contents of the given file at the given commit, or an error wrapping os.ErrNotExist if it
+// doesn't exist there.
+func FileAt(repo *git.Repository, commit plumbing.Hash, p repopath.Path) ([]byte, error) {
+	c, err := repo.CommitObject(commit)
+	if err != nil {
+		return nil, fmt.Errorf("commit %s not found: %w", commit, err)
+	}
+	f, err := c.File(string(p))
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, fmt.Errorf("%s at %s: %w", p, commit, os.ErrNotExist)
+	}
+	if err != nil {
+		return nil, err
+	}
+	contents, err := f.Contents()
+	return []byte(contents), err
+}
 
-	var pathsAdded, pathsModified, pathsDeleted []string
-	for _, f := range diff.FilePatches() {
-		f1, f2 := f.Files()
-		if f1 == nil {
-			pathsAdded = append(pathsAdded, f2.Path())
-		} else if f2 == nil {
-			pathsDeleted = append(pathsDeleted, f1.Path())
-		} else {
-			pathsModified = append(pathsModified, f2.Path())
+func diffCommits(ctx context.Context, baseCommitObj, toCommitObj *object.Commit) (ChangeSet, error) {
+	baseTree, err := baseCommitObj.Tree()
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	toTree, err := toCommitObj.Tree()
+	if err != nil {
+		return ChangeSet{}, err
+	}
+
+	changes, err := object.DiffTreeWithOptions(ctx, baseTree, toTree, diffTreeOptions)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+
+	res := newChangeSet()
+	for _, c := range changes {
+		switch {
+		case c.From.Name == "":
+			res.Added.Add(repopath.Path(c.To.Name))
+		case c.To.Name == "":
+			res.Deleted.Add(repopath.Path(c.From.Name))
+		case c.From.Name != c.To.Name:
+			res.Renamed[repopath.Path(c.From.Name)] = repopath.Path(c.To.Name)
+		default:
+			res.Modified.Add(repopath.Path(c.To.Name))
 		}
 	}
 
-	return ChangeSet{
-		mapset.NewSet(pathsAdded...),
-		mapset.NewSet(pathsModified...),
-		mapset.NewSet(pathsDeleted...),
-	}, nil
+	err = res.detectExactRenamesAndCopies(baseTree, func(p repopath.Path) (plumbing.Hash, error) {
+		e, err := toTree.FindEntry(string(p))
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		return e.Hash, nil
+	})
+	return res, err
+}
+
+func resolveCommit(repo *git.Repository, rev string) (*object.Commit, error) {
+	hash, err := ResolveRevision(repo, rev)
+	if err != nil {
+		return nil, err
+	}
+	c, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("commit %s not found: %w", hash, err)
+	}
+	return c, nil
 }
 
-func (s ChangeSet) All() mapset.Set[string] {
-	return s.Added.Union(s.Modified).Union(s.Deleted)
+// mergeBase returns the best common ancestor of the given commits. Of several equally good ones, as in criss-cross
+// merges, it returns the first, like `git diff A...B` does.
+func mergeBase(a, b *object.Commit) (*object.Commit, error) {
+	bases, err := a.MergeBase(b)
+	if err != nil {
+		return nil, fmt.Errorf("could not compute merge base, which requires the history of both revisions: %w", err)
+	}
+	if len(bases) == 0 {
+		return nil, fmt.Errorf("no merge base between %s and %s", a.Hash, b.Hash)
+	}
+	return bases[0], nil
 }
@@ -0,0 +1,311 @@
+package gitutil
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dorfire/heavenly/pkg/repopath"
+)
+
+type testRepo struct {
+	t    *testing.T
+	repo *git.Repository
+	root string
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	root := t.TempDir()
+	repo, err := git.PlainInit(root, false)
+	require.NoError(t, err)
+	return &testRepo{t, repo, root}
+}
+
+// commit writes the given files, or deletes the ones with empty contents, and commits them.
+func (r *testRepo) commit(files map[string]string) plumbing.Hash {
+	wt, err := r.repo.Worktree()
+	require.NoError(r.t, err)
+
+	for p, content := range files {
+		if content == "" {
+			_, err = wt.Remove(p)
+			require.NoError(r.t, err)
+			continue
+		}
+		abs := filepath.Join(r.root, p)
+		require.NoError(r.t, os.MkdirAll(filepath.Dir(abs), 0o755))
+		require.NoError(r.t, os.WriteFile(abs, []byte(content), 0o644))
+		_, err = wt.Add(p)
+		require.NoError(r.t, err)
+	}
+
+	sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Unix(1700000000, 0)}
+	h, err := wt.Commit("commit", &git.CommitOptions{Author: sig})
+	require.NoError(r.t, err)
+	return h
+}
+
+func (r *testRepo) setRef(name string, h plumbing.Hash) {
+	require.NoError(r.t, r.repo.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName(name), h)))
+}
+
+func paths(set mapset.Set[repopath.Path]) []string {
+	return repopath.Strings(set.ToSlice())
+}
+
+func TestResolveRevision(t *testing.T) {
+	r := newTestRepo(t)
+	first := r.commit(map[string]string{"a.txt": "a"})
+	second := r.commit(map[string]string{"b.txt": "b"})
+	third := r.commit(map[string]string{"a.txt": "a2"})
+
+	r.setRef("refs/heads/feature", second)
+	r.setRef("refs/tags/v1", first)
+	r.setRef("refs/remotes/origin/release", second)
+	_, err := r.repo.CreateTag("v2", second, &git.CreateTagOptions{
+		Tagger: &object.Signature{Name: "test", Email: "test@example.com"}, Message: "v2",
+	})
+	require.NoError(t, err)
+
+	for rev, want := range map[string]plumbing.Hash{
+		"HEAD":                          third,
+		"HEAD~2":                        first,
+		"HEAD^":                         second,
+		third.String():                  third,
+		first.String()[:7]:              first,
+		"master":                        third,
+		"refs/heads/master":             third,
+		"feature":                       second,
+		"feature~1":                     first,
+		"v1":                            first,
+		"v2":                            second,
+		"origin/release":                second,
+		"release":                       second, // Falls back to the remote-tracking branch
+		"release^":                      first,
+		"refs/remotes/origin/release^1": first,
+	} {
+		got, err := ResolveRevision(r.repo, rev)
+		if assert.NoError(t, err, rev) {
+			assert.Equal(t, want, got, rev)
+		}
+	}
+
+	for _, rev := range []string{"", "nonexistent", "origin/nonexistent", "HEAD~5", "0000000"} {
+		_, err := ResolveRevision(r.repo, rev)
+		assert.ErrorIs(t, err, ErrRevisionNotFound, rev)
+	}
+}
+
+func TestResolveRevisionAmbiguous(t *testing.T) {
+	r := newTestRepo(t)
+	first := r.commit(map[string]string{"a.txt": "a"})
+	second := r.commit(map[string]string{"b.txt": "b"})
+
+	// A branch and a tag by the same name, pointing to different commits
+	r.setRef("refs/heads/release", first)
+	r.setRef("refs/tags/release", second)
+	_, err := ResolveRevision(r.repo, "release~1")
+	assert.ErrorIs(t, err, ErrAmbiguousRevision)
+	assert.ErrorContains(t, err, "refs/heads/release")
+	assert.ErrorContains(t, err, "refs/tags/release")
+
+	// A branch named like a short hash of another commit
+	short := first.String()[:8]
+	r.setRef("refs/heads/"+short, second)
+	_, err = ResolveRevision(r.repo, short)
+	assert.ErrorIs(t, err, ErrAmbiguousRevision)
+	// Odd-length ones too, which can't be looked up as whole bytes
+	short = first.String()[:7]
+	r.setRef("refs/heads/"+short, second)
+	_, err = ResolveRevision(r.repo, short)
+	assert.ErrorIs(t, err, ErrAmbiguousRevision)
+
+	// A branch and a tag by the same name, pointing to the same commit, are fine
+	r.setRef("refs/heads/v1", second)
+	r.setRef("refs/tags/v1", second)
+	got, err := ResolveRevision(r.repo, "v1")
+	assert.NoError(t, err)
+	assert.Equal(t, second, got)
+}
+
+func TestFilesChanged(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit(map[string]string{"a.txt": "a", "b.txt": "b"})
+	r.setRef("refs/remotes/origin/main", r.commit(map[string]string{"c/d.txt": "d"}))
+	r.commit(map[string]string{"a.txt": "a2", "b.txt": "", "e.txt": "e"})
+
+	cs, err := FilesChanged(context.Background(), r.repo, "main", "HEAD", DiffOptions{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"e.txt"}, paths(cs.Added))
+	assert.ElementsMatch(t, []string{"a.txt"}, paths(cs.Modified))
+	assert.ElementsMatch(t, []string{"b.txt"}, paths(cs.Deleted))
+
+	_, err = FilesChanged(context.Background(), r.repo, "nonexistent", "HEAD", DiffOptions{})
+	assert.ErrorIs(t, err, ErrRevisionNotFound)
+	assert.ErrorContains(t, err, "base revision")
+
+	b, err := FileAt(r.repo, cs.Base, "b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "b", string(b))
+	_, err = FileAt(r.repo, cs.Base, "e.txt")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	e, err := FileAt(r.repo, cs.Target, "e.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "e", string(e))
+	assert.Equal(t, DiffCommit, cs.Uncommitted)
+}
+
+func TestFilesChangedMergeBase(t *testing.T) {
+	r := newTestRepo(t)
+	branchPoint := r.commit(map[string]string{"a.txt": "a", "b.txt": "b"})
+	r.setRef("refs/heads/main", r.commit(map[string]string{"main.txt": "main"}))
+
+	// A PR branch, which doesn't include main.txt
+	r.setRef("refs/heads/pr", branchPoint)
+	wt, err := r.repo.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, wt.Checkout(&git.CheckoutOptions{Branch: "refs/heads/pr"}))
+	r.commit(map[string]string{"b.txt": "b2"})
+
+	cs, err := FilesChanged(context.Background(), r.repo, "main", "pr", DiffOptions{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"b.txt", "main.txt"}, paths(cs.All()))
+
+	cs, err = FilesChanged(context.Background(), r.repo, "main", "pr", DiffOptions{MergeBase: true})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"b.txt"}, paths(cs.All()))
+}
+
+func TestFilesChangedUncommitted(t *testing.T) {
+	r := newTestRepo(t)
+	base := r.commit(map[string]string{"a.txt": "a", "b.txt": "b", "c.txt": "c"})
+	r.commit(map[string]string{"d.txt": "d"})
+
+	wt, err := r.repo.Worktree()
+	require.NoError(t, err)
+	write := func(p, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(r.root, p), []byte(content), 0o644))
+	}
+	write("a.txt", "a2") // Staged
+	_, err = wt.Add("a.txt")
+	require.NoError(t, err)
+	write("b.txt", "b2") // Unstaged
+	write("e.txt", "e")  // Untracked
+	_, err = wt.Remove("c.txt")
+	require.NoError(t, err)
+	write("d.txt", "d") // Unchanged
+
+	for _, tc := range []struct {
+		name                     string
+		from                     string
+		target                   DiffTarget
+		added, modified, deleted []string
+	}{
+		{"staged", "HEAD", DiffStaged, nil, []string{"a.txt"}, []string{"c.txt"}},
+		{"worktree", "HEAD", DiffWorktree, []string{"e.txt"}, []string{"a.txt", "b.txt"}, []string{"c.txt"}},
+		{"staged against base", base.String(), DiffStaged, []string{"d.txt"}, []string{"a.txt"}, []string{"c.txt"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cs, err := FilesChanged(context.Background(), r.repo, tc.from, "HEAD", DiffOptions{Target: tc.target})
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.added, paths(cs.Added))
+			assert.ElementsMatch(t, tc.modified, paths(cs.Modified))
+			assert.ElementsMatch(t, tc.deleted, paths(cs.Deleted))
+			assert.Equal(t, tc.target, cs.Uncommitted)
+		})
+	}
+
+	a, err := StagedFile(r.repo, "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "a2", string(a))
+	_, err = StagedFile(r.repo, "e.txt")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = FilesChanged(context.Background(), r.repo, "HEAD", "HEAD~1", DiffOptions{Target: DiffWorktree})
+	assert.Error(t, err)
+
+	// Failures to read the index or working tree aren't reported as an empty change set
+	require.NoError(t, os.WriteFile(filepath.Join(r.root, ".git", "index"), []byte("corrupt"), 0o644))
+	for _, target := range []DiffTarget{DiffStaged, DiffWorktree} {
+		cs, err := FilesChanged(context.Background(), r.repo, "HEAD", "HEAD", DiffOptions{Target: target})
+		assert.Error(t, err)
+		assert.Nil(t, cs.Added)
+	}
+}
+
+func TestFilesChangedRenames(t *testing.T) {
+	long := strings.Repeat("line\n", 20)
+	r := newTestRepo(t)
+	base := r.commit(map[string]string{"a.txt": long + "a", "b.txt": long + "b", "c.txt": "c", "d.txt": "d"})
+	r.commit(map[string]string{
+		"a.txt": "", "a2.txt": long + "a", // Exact rename
+		"b.txt": "", "b2.txt": long + "b2", // Rename with modifications
+		"c.txt": "", "c2.txt": "c2", // Unrelated deletion and addition
+		"d2.txt": "d", // Copy
+	})
+
+	cs, err := FilesChanged(context.Background(), r.repo, "HEAD~1", "HEAD", DiffOptions{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"c2.txt"}, paths(cs.Added))
+	assert.ElementsMatch(t, []string{"c.txt"}, paths(cs.Deleted))
+	assert.Empty(t, paths(cs.Modified))
+	assert.Equal(t, map[repopath.Path]repopath.Path{"a.txt": "a2.txt", "b.txt": "b2.txt"}, cs.Renamed)
+	assert.Equal(t, map[repopath.Path]repopath.Path{"d2.txt": "d.txt"}, cs.Copied)
+	assert.ElementsMatch(t, []string{"a.txt", "a2.txt", "b.txt", "b2.txt", "c.txt", "c2.txt", "d2.txt"},
+		paths(cs.All()))
+
+	jsonBytes, err := json.Marshal(cs)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"added": ["c2.txt"],
+		"modified": [],
+		"deleted": ["c.txt"],
+		"renamed": {"a.txt": "a2.txt", "b.txt": "b2.txt"},
+		"copied": {"d2.txt": "d.txt"},
+		"base": "`+base.String()+`",
+		"target": "`+cs.Target.String()+`"
+	}`, string(jsonBytes))
+
+	// Renames are detected the same way in the working tree and the index
+	require.NoError(t, os.Rename(filepath.Join(r.root, "a2.txt"), filepath.Join(r.root, "a3.txt")))
+	require.NoError(t, os.Rename(filepath.Join(r.root, "b2.txt"), filepath.Join(r.root, "b3.txt")))
+	require.NoError(t, os.WriteFile(filepath.Join(r.root, "b3.txt"), []byte(long+"b3"), 0o644))
+	require.NoError(t, os.Rename(filepath.Join(r.root, "c2.txt"), filepath.Join(r.root, "c3.txt")))
+	require.NoError(t, os.WriteFile(filepath.Join(r.root, "c3.txt"), []byte("c3"), 0o644))
+	cs, err = FilesChanged(context.Background(), r.repo, "HEAD", "HEAD", DiffOptions{Target: DiffWorktree})
+	require.NoError(t, err)
+	assert.Equal(t, map[repopath.Path]repopath.Path{"a2.txt": "a3.txt", "b2.txt": "b3.txt"}, cs.Renamed)
+	assert.ElementsMatch(t, []string{"c3.txt"}, paths(cs.Added))
+	assert.ElementsMatch(t, []string{"c2.txt"}, paths(cs.Deleted))
+
+	wt, err := r.repo.Worktree()
+	require.NoError(t, err)
+	for _, p := range []string{"a2.txt", "a3.txt", "b2.txt", "b3.txt"} {
+		_, err = wt.Add(p)
+		require.NoError(t, err)
+	}
+	cs, err = FilesChanged(context.Background(), r.repo, "HEAD", "HEAD", DiffOptions{Target: DiffStaged})
+	require.NoError(t, err)
+	assert.Equal(t, map[repopath.Path]repopath.Path{"a2.txt": "a3.txt", "b2.txt": "b3.txt"}, cs.Renamed)
+	assert.Empty(t, paths(cs.Added))
+	assert.Empty(t, paths(cs.Deleted))
+}
+
+func TestSimilarity(t *testing.T) {
+	long := strings.Repeat("line\n", 20)
+	assert.Equal(t, 100, similarity([]byte(long), []byte(long)))
+	assert.GreaterOrEqual(t, similarity([]byte(long+"a"), []byte(long+"b")), 50)
+	assert.Less(t, similarity([]byte("a\nb\n"), []byte("c\nd\n")), 50)
+	assert.Equal(t, 0, similarity(nil, nil))
+}
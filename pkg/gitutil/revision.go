@@ -0,0 +1,148 @@
+package gitutil
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/samber/lo"
+)
+
+const (
+	defaultRemote = "origin"
+)
+
+var (
+	ErrRevisionNotFound  = errors.New("revision not found")
+	ErrAmbiguousRevision = errors.New("ambiguous revision")
+
+	shortHashRe = regexp.MustCompile(`^[0-9a-f]{4,39}$`)
+	// The ref name at the start of a revision expression, e.g. `main` in `main~3`
+	revisionRefRe = regexp.MustCompile(`^[^~^:@]+`)
+)
+
+// ResolveRevision resolves a git revision expression to a commit hash, like `git rev-parse <rev>^{commit}` does.
+// rev may be a full or short commit hash, a branch, tag or remote-tracking branch name, or any of these followed by
+// `~N` or `^N`. Branches which only exist on the default remote, as in shallow CI checkouts, are resolved via their
+// remote-tracking branch.
+func ResolveRevision(repo *git.Repository, rev string) (plumbing.Hash, error) {
+	if rev == "" {
+		return plumbing.ZeroHash, fmt.Errorf("%w: empty revision", ErrRevisionNotFound)
+	}
+
+	if err := checkAmbiguous(repo, revisionRefRe.FindString(rev)); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if errors.Is(err, plumbing.ErrReferenceNotFound) && !strings.HasPrefix(rev, defaultRemote+"/") {
+		hash, err = repo.ResolveRevision(plumbing.Revision(defaultRemote + "/" + rev))
+	}
+	// go-git returns io.EOF when an ancestor is out of the history's bounds, e.g. HEAD~5 of a repo with 3 commits
+	if errors.Is(err, plumbing.ErrReferenceNotFound) || errors.Is(err, plumbing.ErrObjectNotFound) ||
+		errors.Is(err, io.EOF) {
+		return plumbing.ZeroHash, fmt.Errorf("%w: %q", ErrRevisionNotFound, rev)
+	}
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("could not resolve revision %q: %w", rev, err)
+	}
+
+	return *hash, nil
+}
+
+// checkAmbiguous returns an error if the given ref name or short hash, as at the start of a revision expression, could
+// refer to several commits: e.g. a branch and a tag by the same name, or a short hash which prefixes several commits'
+// hashes. `git rev-parse` merely warns about these, but silently diffing against the wrong commit is worse.
+func checkAmbiguous(repo *git.Repository, name string) error {
+	if name == "" || name == "HEAD" {
+		return nil
+	}
+
+	var matches []string
+	commits := map[plumbing.Hash]bool{}
+	for _, rule := range append([]string{"%s"}, plumbing.RefRevParseRules...) {
+		ref, err := repo.Reference(plumbing.ReferenceName(fmt.Sprintf(rule, name)), true)
+		if err != nil || lo.Contains(matches, ref.Name().String()) {
+			continue
+		}
+		matches = append(matches, ref.Name().String())
+		commits[peelToCommit(repo, ref.Hash())] = true
+	}
+
+	if shortHashRe.MatchString(name) {
+		hashes, err := commitsWithPrefix(repo, name)
+		if err != nil {
+			return err
+		}
+		for _, h := range hashes {
+			matches = append(matches, "commit "+h.String())
+			commits[h] = true
+		}
+	}
+
+	if len(commits) > 1 {
+		return fmt.Errorf("%w: %q matches %s", ErrAmbiguousRevision, name, strings.Join(matches, ", "))
+	}
+	return nil
+}
+
+// commitsWithPrefix returns the commits whose hashes start with the given hex prefix. Like go-git's ResolveRevision, it
+// looks them up in the object index of filesystem storage, and only walks all commits in other storage.
+func commitsWithPrefix(repo *git.Repository, prefix string) ([]plumbing.Hash, error) {
+	type prefixLookup interface {
+		HashesWithPrefix(prefix []byte) ([]plumbing.Hash, error)
+	}
+	var res []plumbing.Hash
+	if st, ok := repo.Storer.(prefixLookup); ok {
+		// Only whole bytes can be looked up; an odd digit is matched below
+		b, err := hex.DecodeString(prefix[:len(prefix)&^1])
+		if err != nil {
+			return nil, err
+		}
+		hashes, err := st.HashesWithPrefix(b)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range hashes {
+			if !strings.HasPrefix(h.String(), prefix) {
+				continue
+			}
+			// Blobs and trees aren't revisions
+			if _, err := repo.CommitObject(h); err == nil {
+				res = append(res, h)
+			}
+		}
+		return res, nil
+	}
+
+	iter, err := repo.CommitObjects()
+	if err != nil {
+		return nil, err
+	}
+	err = iter.ForEach(func(c *object.Commit) error {
+		if strings.HasPrefix(c.Hash.String(), prefix) {
+			res = append(res, c.Hash)
+		}
+		return nil
+	})
+	return res, err
+}
+
+// peelToCommit returns the commit an annotated tag points to, or the given hash if it isn't one.
+func peelToCommit(repo *git.Repository, h plumbing.Hash) plumbing.Hash {
+	tag, err := repo.TagObject(h)
+	if err != nil {
+		return h
+	}
+	c, err := tag.Commit()
+	if err != nil {
+		return h
+	}
+	return c.Hash
+}
@@ -0,0 +1,203 @@
+package gitutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/dorfire/heavenly/pkg/repopath"
+)
+
+// DiffTarget is the state of the repo which FilesChanged diffs against the base revision.
+type DiffTarget int
+
+const (
+	DiffCommit   DiffTarget = iota // The target revision
+	DiffStaged                     // The index, like `git diff --cached <base>`
+	DiffWorktree                   // The working tree, including untracked files, like `git diff <base>`
+)
+
+// uncommittedChanges returns the files changed between the given base commit and the index or the working tree.
+// Changes committed since base are included, as these are in the index and working tree as well.
+func uncommittedChanges(
+	ctx context.Context, repo *git.Repository, base, head *object.Commit, target DiffTarget,
+) (ChangeSet, error) {
+	wt, err := repo.Worktree()
+	if err != nil {
+		return ChangeSet{}, fmt.Errorf("could not open git worktree: %w", err)
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return ChangeSet{}, fmt.Errorf("could not get worktree status: %w", err)
+	}
+
+	// Paths which may differ between base and target: ones that changed in commits since base, and uncommitted ones
+	paths := mapset.NewSet[repopath.Path]()
+	if base.Hash != head.Hash {
+		committed, err := diffCommits(ctx, base, head)
+		if err != nil {
+			return ChangeSet{}, err
+		}
+		paths = paths.Union(committed.All())
+	}
+	for p, s := range status {
+		if s.Staging != git.Unmodified && s.Staging != git.Untracked ||
+			target == DiffWorktree && s.Worktree != git.Unmodified {
+			paths.Add(repopath.Path(p))
+		}
+	}
+
+	baseTree, err := base.Tree()
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return ChangeSet{}, fmt.Errorf("could not read git index: %w", err)
+	}
+
+	targetHash := func(p repopath.Path) (plumbing.Hash, error) {
+		if target == DiffStaged {
+			return indexHash(idx, string(p))
+		}
+		return worktreeHash(wt, string(p))
+	}
+
+	res := newChangeSet()
+	for _, p := range paths.ToSlice() {
+		var baseHash plumbing.Hash
+		if e, err := baseTree.FindEntry(string(p)); err == nil {
+			baseHash = e.Hash
+		}
+		targetHash, err := targetHash(p)
+		if err != nil {
+			return ChangeSet{}, fmt.Errorf("%s: %w", p, err)
+		}
+
+		switch {
+		case baseHash == targetHash:
+		case baseHash.IsZero():
+			res.Added.Add(p)
+		case targetHash.IsZero():
+			res.Deleted.Add(p)
+		default:
+			res.Modified.Add(p)
+		}
+	}
+
+	if err = res.detectExactRenamesAndCopies(baseTree, targetHash); err != nil {
+		return ChangeSet{}, err
+	}
+	// go-git only detects renames with modifications between trees, so these are detected here
+	baseFile := func(p repopath.Path) ([]byte, error) {
+		f, err := baseTree.File(string(p))
+		if err != nil {
+			return nil, err
+		}
+		content, err := f.Contents()
+		return []byte(content), err
+	}
+	targetFile := func(p repopath.Path) ([]byte, error) {
+		if target == DiffStaged {
+			return StagedFile(repo, p)
+		}
+		return worktreeFile(wt, string(p))
+	}
+	return res, res.detectSimilarRenames(baseFile, targetFile)
+}
+
+// StagedFile returns the contents of the given file in the index, or an error wrapping os.ErrNotExist if it isn't in
+// it.
+func StagedFile(repo *git.Repository, p repopath.Path) ([]byte, error) {
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("could not read git index: %w", err)
+	}
+	h, err := indexHash(idx, string(p))
+	if err != nil {
+		return nil, err
+	}
+	if h.IsZero() {
+		return nil, fmt.Errorf("%s in the index: %w", p, os.ErrNotExist)
+	}
+
+	blob, err := repo.BlobObject(h)
+	if err != nil {
+		return nil, err
+	}
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// indexHash returns the blob hash of the given path in the index, or the zero hash if it isn't in it.
+func indexHash(idx *index.Index, p string) (plumbing.Hash, error) {
+	e, err := idx.Entry(p)
+	if errors.Is(err, index.ErrEntryNotFound) {
+		return plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return e.Hash, nil
+}
+
+// worktreeFile returns the contents of the given path in the working tree; the target of a symlink.
+func worktreeFile(wt *git.Worktree, p string) ([]byte, error) {
+	fi, err := wt.Filesystem.Lstat(p)
+	if err != nil {
+		return nil, err
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := wt.Filesystem.Readlink(p)
+		return []byte(target), err
+	}
+
+	f, err := wt.Filesystem.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// worktreeHash returns the blob hash of the given path in the working tree, or the zero hash if it doesn't exist.
+func worktreeHash(wt *git.Worktree, p string) (plumbing.Hash, error) {
+	fi, err := wt.Filesystem.Lstat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := wt.Filesystem.Readlink(p)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		return plumbing.ComputeHash(plumbing.BlobObject, []byte(target)), nil
+	}
+
+	f, err := wt.Filesystem.Open(p)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	defer f.Close()
+	h := plumbing.NewHasher(plumbing.BlobObject, fi.Size())
+	if _, err := io.Copy(h, f); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return h.Sum(), nil
+}
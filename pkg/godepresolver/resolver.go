@@ -56,6 +56,12 @@ func New(goModDir string, log conslogging.ConsoleLogger) (*GoDepResolver, error)
 	}, nil
 }
 
+// ProjectRoot returns the dir of the top-most Earthfile above the Go module, which $TOP refers to in resolved COPY
+// commands.
+func (r *GoDepResolver) ProjectRoot() string {
+	return r.projRoot
+}
+
 // ResolveImportsToCopyCommands resolves internal Go imports in a given package to the COPY commands they probably depend on.
 // This is a poor man's Gazelle - it presumes every imported directory has an Earthfile in it, or above it, with a +src
 // target, but doesn't actually ensure it includes the required Go source files or that it even exists.
@@ -154,12 +160,12 @@ func (r *GoDepResolver) resolveCopyCommandForGoImport(importerDir, importeeDir s
 		return spec.Command{Name: "COPY", Args: []string{"--dir", "+src/*", "."}}, nil
 	}
 
-	src, err := formatCopyCmdSrc(r.projRoot, importeeDir, resolvedEarthdir)
+	src, err := earthdir.SrcArtifact(r.projRoot, importeeDir, resolvedEarthdir)
 	if err != nil {
 		return spec.Command{}, err
 	}
 
-	args := []string{"--dir", src, replaceRootWithTopArg(importeeDir, r.goModRoot) + "/"}
+	args := []string{"--dir", src, earthdir.WithTopArg(importeeDir, r.goModRoot) + "/"}
 	if transitive {
 		args = append(args, "# indirect")
 	}
@@ -174,24 +180,6 @@ func (r *GoDepResolver) pkgGoPathToFSDir(importPath string) string {
 	return strings.Replace(importPath, r.goModFile.Module.Mod.Path, r.goModRoot, 1)
 }
 
-func formatCopyCmdSrc(projRoot, dirInProject, closestEarthdir string) (string, error) {
-	relToClosestEarthfile, err := filepath.Rel(closestEarthdir, dirInProject)
-	if err != nil {
-		return "", err
-	}
-
-	srcDirWithTopArg := replaceRootWithTopArg(closestEarthdir, projRoot)
-
-	if relToClosestEarthfile != "." {
-		return fmt.Sprintf("%s/+src/%s/*", srcDirWithTopArg, relToClosestEarthfile), nil
-	}
-	return fmt.Sprintf("%s/+src/*", srcDirWithTopArg), nil
-}
-
-func replaceRootWithTopArg(s, projRoot string) string {
-	return strings.Replace(s, projRoot, "$TOP", 1)
-}
-
 func extend[K comparable, V any](dst, src map[K]V) {
 	for k, v := range src {
 		if _, ok := dst[k]; !ok {
@@ -29,3 +29,25 @@ func assertSetsEqual(t *testing.T, want, got mapset.Set[string]) {
 		t.Logf("Difference: %v", want.Difference(got).ToSlice())
 	}
 }
+
+func TestModulePackages(t *testing.T) {
+	mods, err := goparse.FindModules("testdata")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"testdata/pkga"}, mods)
+
+	pkgs, err := goparse.ModulePackages("testdata/pkga")
+	assert.NoError(t, err)
+	if !assert.Len(t, pkgs, 2) {
+		return
+	}
+
+	assert.Equal(t, "example.com/pkga", pkgs[0].ImportPath)
+	assert.Equal(t, "pkga", pkgs[0].Name)
+	assert.Len(t, pkgs[0].Files, 2)
+	assert.True(t, pkgs[0].Files[1].Test)
+
+	assert.Equal(t, "example.com/pkga/cmd", pkgs[1].ImportPath)
+	assert.Equal(t, "main", pkgs[1].Name)
+	assert.Equal(t, "example.com/pkga", pkgs[1].Files[0].Imports[0].Path)
+	assert.Equal(t, 4, pkgs[1].Files[0].Imports[0].Pos.Line)
+}
@@ -0,0 +1,173 @@
+package goparse
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"golang.org/x/exp/maps"
+)
+
+var (
+	dirsToSkip = mapset.NewThreadUnsafeSet("testdata", "node_modules", "vendor")
+)
+
+// Import is an import spec in a Go source file.
+type Import struct {
+	Path string
+	Pos  token.Position
+}
+
+// File is a Go source file, parsed up to its imports.
+type File struct {
+	Path       string
+	Package    string         // Name in the package clause, e.g. "foo" or "foo_test"
+	PackagePos token.Position // Position of the package clause
+	Test       bool
+	Imports    []Import
+	Err        error // Set if the file's package clause or imports could not be parsed
+}
+
+// Package is a Go package in a module, along with its test files.
+type Package struct {
+	Dir        string
+	ImportPath string
+	Name       string // Declared by the non-test files; empty if the package only has test files
+	Files      []File
+}
+
+// FindModules returns the dirs of all Go modules in the given dir and its subdirs.
+func FindModules(root string) ([]string, error) {
+	var res []string
+	err := walkSourceDirs(root, func(dir string) error {
+		if _, err := os.Stat(filepath.Join(dir, goModName)); err == nil {
+			res = append(res, dir)
+		}
+		return nil
+	})
+	return res, err
+}
+
+// ModulePackages returns all packages in the module rooted at modDir, skipping nested modules.
+func ModulePackages(modDir string) ([]Package, error) {
+	mod, err := ModFile(modDir)
+	if err != nil {
+		return nil, err
+	}
+	if mod.Module == nil {
+		return nil, fmt.Errorf("no module directive in %s", filepath.Join(modDir, goModName))
+	}
+
+	var res []Package
+	err = walkSourceDirs(modDir, func(dir string) error {
+		if _, err := os.Stat(filepath.Join(dir, goModName)); err == nil && dir != modDir {
+			return filepath.SkipDir
+		}
+
+		files, err := DirFiles(dir)
+		if err != nil || len(files) == 0 {
+			return err
+		}
+
+		rel, err := filepath.Rel(modDir, dir)
+		if err != nil {
+			return err
+		}
+		res = append(res, Package{
+			Dir:        dir,
+			ImportPath: path.Join(mod.Module.Mod.Path, filepath.ToSlash(rel)),
+			Name:       packageName(files),
+			Files:      files,
+		})
+		return nil
+	})
+	return res, err
+}
+
+// DirFiles parses the package clauses and imports of all Go files in dir, including test files. Files which can't be
+// parsed are returned with their Err set, rather than failing the whole dir.
+func DirFiles(dir string) ([]File, error) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	var res []File
+	for _, e := range ents {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".go") {
+			continue
+		}
+		p := filepath.Join(dir, e.Name())
+		file := File{Path: p, Test: strings.HasSuffix(p, "_test.go")}
+		f, err := parser.ParseFile(fset, p, nil, parser.ImportsOnly)
+		if err != nil {
+			file.Err = err
+			res = append(res, file)
+			continue
+		}
+
+		file.Package, file.PackagePos = f.Name.Name, fset.Position(f.Package)
+		for _, i := range f.Imports {
+			file.Imports = append(file.Imports, Import{
+				Path: strings.Trim(i.Path.Value, `"`),
+				Pos:  fset.Position(i.Pos()),
+			})
+		}
+		res = append(res, file)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Path < res[j].Path })
+	return res, nil
+}
+
+// packageName returns the package name declared by the most given non-test files.
+// Directories sometimes hold `package main` files excluded by build tags (e.g. generators); other names win over it.
+// Ties are broken deterministically, in favor of names without a `_test` suffix, then alphabetically.
+func packageName(files []File) string {
+	names := map[string]int{}
+	for _, f := range files {
+		if !f.Test && f.Err == nil {
+			names[f.Package]++
+		}
+	}
+	if len(names) > 1 {
+		delete(names, "main")
+	}
+	keys := maps.Keys(names)
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if names[a] != names[b] {
+			return names[a] > names[b]
+		}
+		if aTest, bTest := strings.HasSuffix(a, "_test"), strings.HasSuffix(b, "_test"); aTest != bTest {
+			return bTest
+		}
+		return a < b
+	})
+	if len(keys) == 0 {
+		return ""
+	}
+	return keys[0]
+}
+
+func walkSourceDirs(root string, fn func(dir string) error) error {
+	return filepath.WalkDir(root, func(p string, ent fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !ent.IsDir() {
+			return nil
+		}
+		if p != root && (dirsToSkip.Contains(ent.Name()) || strings.HasPrefix(ent.Name(), ".")) {
+			return filepath.SkipDir
+		}
+		return fn(p)
+	})
+}
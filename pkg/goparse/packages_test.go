@@ -0,0 +1,42 @@
+package goparse
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackageName(t *testing.T) {
+	files := func(names ...string) []File {
+		var res []File
+		for _, n := range names {
+			res = append(res, File{Package: n})
+		}
+		return res
+	}
+
+	for _, tc := range []struct {
+		name  string
+		files []File
+		want  string
+	}{
+		{"none", nil, ""},
+		{"single", files("foo", "foo"), "foo"},
+		{"majority", files("foo", "bar", "bar"), "bar"},
+		{"main loses", files("main", "main", "foo"), "foo"},
+		{"only main", files("main"), "main"},
+		{"test suffix loses ties", files("foo_test", "foo"), "foo"},
+		{"alphabetical ties", files("foo", "bar"), "bar"},
+		{"test files and errors are ignored", append(files("foo"),
+			File{Package: "bar", Test: true}, File{Package: "bar", Test: true},
+			File{Package: "baz", Err: errors.New("broken")}), "foo"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// Names are counted in a map, whose iteration order varies
+			for i := 0; i < 20; i++ {
+				assert.Equal(t, tc.want, packageName(tc.files))
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package lint
+
+import (
+	"strings"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/earthly/earthly/ast/spec"
+	"github.com/samber/lo"
+
+	"github.com/dorfire/heavenly/pkg/earthfile"
+)
+
+var (
+	// Commands whose args are interpreted by a shell in the build container, where variables may come from anywhere
+	shellCmds = mapset.NewThreadUnsafeSet("RUN", "CMD", "ENTRYPOINT", "HEALTHCHECK")
+)
+
+func init() {
+	Register(undefinedArg{})
+	Register(unusedArg{})
+	Register(missingRequiredArg{})
+}
+
+// undefinedArg reports variable references which refer to no ARG, ENV or FOR variable in scope. References in RUN
+// and similar commands are not checked, since the shell running them may define any variable.
+type undefinedArg struct{}
+
+func (undefinedArg) Name() string { return "undefined-arg" }
+func (undefinedArg) Doc() string {
+	return "variable reference to an undefined ARG"
+}
+func (undefinedArg) DefaultSeverity() Severity { return SeverityError }
+
+func (undefinedArg) Check(p *Pass) error {
+	for _, ef := range p.Repo.Earthfiles {
+		globalNames := recipeVars(ef.Spec.BaseRecipe).argNames()
+
+		recipes(ef, func(t *spec.Target, recipe spec.Block) {
+			vars := recipeVars(recipe)
+			defined := mapset.NewThreadUnsafeSet(globalNames...)
+			defined = defined.Union(mapset.NewThreadUnsafeSet(vars.argNames()...))
+			defined = defined.Union(mapset.NewThreadUnsafeSet(vars.envs...))
+			scope := "global ARG"
+			if t != nil {
+				// ENVs persist in the images of targets based on the one declaring them
+				for _, ft := range fromChain(ef, t)[1:] {
+					defined = defined.Union(mapset.NewThreadUnsafeSet(recipeVars(ft.target.Recipe).envs...))
+				}
+				scope = "ARG of target +" + t.Name + " nor a global ARG"
+			}
+
+			reported := mapset.NewThreadUnsafeSet[string]()
+			for _, ref := range vars.refs {
+				if !ref.checked || defined.Contains(ref.name) || ref.loc == nil {
+					continue
+				}
+				pos := p.Repo.Pos(ef, ref.loc)
+				if reported.Add(pos.String() + "$" + ref.name) {
+					p.Reportf(pos, "`$%s` refers to no %s", ref.name, scope)
+				}
+			}
+		})
+	}
+	return nil
+}
+
+// unusedArg reports ARGs which are declared but never referenced in their scope. ARGs are also exported as environment
+// variables to RUN commands, which scripts and Makefiles may read without referencing them in the Earthfile; as these
+// can't be told apart from unused ARGs, the rule only informs.
+type unusedArg struct{}
+
+func (unusedArg) Name() string { return "unused-arg" }
+func (unusedArg) Doc() string {
+	return "ARG which is declared but never referenced (or only read by RUN commands, from their environment)"
+}
+func (unusedArg) DefaultSeverity() Severity { return SeverityInfo }
+
+func (unusedArg) Check(p *Pass) error {
+	for _, ef := range p.Repo.Earthfiles {
+		fileRefs := mapset.NewThreadUnsafeSet[string]()
+		for _, cmd := range ef.Spec.UserCommands {
+			fileRefs = fileRefs.Union(mapset.NewThreadUnsafeSet(recipeVars(cmd.Recipe).refNames()...))
+		}
+
+		recipes(ef, func(t *spec.Target, recipe spec.Block) {
+			vars := recipeVars(recipe)
+			fileRefs = fileRefs.Union(mapset.NewThreadUnsafeSet(vars.refNames()...))
+			if t == nil {
+				return
+			}
+
+			refs := mapset.NewThreadUnsafeSet(vars.refNames()...)
+			for _, a := range vars.args {
+				if !refs.Contains(a.Name) {
+					p.Reportf(p.Repo.Pos(ef, a.loc), "ARG %s is never referenced in target +%s", a.Name, t.Name)
+				}
+			}
+		})
+
+		for _, a := range recipeVars(ef.Spec.BaseRecipe).args {
+			if !fileRefs.Contains(a.Name) {
+				p.Reportf(p.Repo.Pos(ef, a.loc), "global ARG %s is never referenced in the Earthfile", a.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// missingRequiredArg reports BUILD, FROM and COPY commands which don't pass an `ARG --required` of the target they
+// invoke.
+type missingRequiredArg struct{}
+
+func (missingRequiredArg) Name() string { return "missing-required-arg" }
+func (missingRequiredArg) Doc() string {
+	return "BUILD, FROM or COPY command which does not pass a required ARG"
+}
+func (missingRequiredArg) DefaultSeverity() Severity { return SeverityError }
+
+func (missingRequiredArg) Check(p *Pass) error {
+	for _, ef := range p.Repo.Earthfiles {
+		recipes(ef, func(_ *spec.Target, recipe spec.Block) {
+			for _, c := range commands(recipe) {
+				if lo.Contains(c.Args, "--pass-args") {
+					continue
+				}
+				for _, call := range targetCalls(c) {
+					ref := ef.ExpandArgs(call.Target)
+					if !earthfile.IsLocalTargetRef(ref) || strings.ContainsRune(ref, '$') {
+						continue
+					}
+					_, callee, err := ef.Target(ref)
+					if err != nil {
+						continue // Reported by other rules
+					}
+
+					for _, a := range recipeVars(callee.Recipe).args {
+						if _, ok := call.BuildArgs[a.Name]; a.Required && !ok {
+							p.Reportf(p.Repo.Pos(ef, c.SourceLocation), "%s %s does not pass ARG %s, which it requires",
+								c.Name, call.Target, a.Name)
+						}
+					}
+				}
+			}
+		})
+	}
+	return nil
+}
+
+// targetCalls returns the target calls of a BUILD or FROM command, or of the artifact references of a COPY command,
+// which invoke their targets too, with the COPY command's build args and their own.
+func targetCalls(c spec.Command) []earthfile.TargetCall {
+	switch c.Name {
+	case "BUILD", "FROM":
+		call, err := earthfile.ParseTargetCall(c.Args)
+		if err != nil {
+			return nil
+		}
+		return []earthfile.TargetCall{call}
+	case "COPY":
+		args, err := earthfile.ParseCopyArgs(c.Args)
+		if err != nil {
+			return nil
+		}
+		var res []earthfile.TargetCall
+		for _, src := range args.Sources {
+			// e.g. `(+target/artifact --ARG=val)`
+			fields := strings.Fields(strings.Trim(src, "()"))
+			if !earthfile.IsLocalTargetRef(fields[0]) {
+				continue
+			}
+			target, _ := earthfile.SplitArtifactRef(fields[0])
+			call, err := earthfile.ParseTargetCall(append(append(lo.Flatten(args.Flags), target), fields[1:]...))
+			if err == nil {
+				res = append(res, call)
+			}
+		}
+		return res
+	default:
+		return nil
+	}
+}
+
+type argDecl struct {
+	earthfile.ArgDecl
+	loc *spec.SourceLocation
+}
+
+type varRef struct {
+	name    string
+	loc     *spec.SourceLocation
+	checked bool // Whether Earthly expands the reference, as opposed to a shell in the build container
+}
+
+// vars holds the variables a recipe declares and references.
+type vars struct {
+	args []argDecl
+	envs []string // Names of ENV, LET and FOR variables
+	refs []varRef
+}
+
+func (v *vars) argNames() []string {
+	return lo.Map(v.args, func(a argDecl, _ int) string { return a.Name })
+}
+
+func (v *vars) refNames() []string {
+	return lo.Map(v.refs, func(r varRef, _ int) string { return r.name })
+}
+
+type varCollector struct {
+	earthfile.UnimplementedStmtVisitor
+	vars
+}
+
+// recipeVars collects the variables declared and referenced in a recipe, including in nested blocks.
+// ARGs with unexpected syntax are skipped.
+func recipeVars(recipe spec.Block) *vars {
+	v := &varCollector{}
+	earthfile.WalkRecipe(recipe, v)
+	return &v.vars
+}
+
+func (v *varCollector) VisitCommand(c spec.Command) {
+	args := c.Args
+	switch c.Name {
+	case "ARG":
+		decl, err := earthfile.ParseArgDecl(c.Args)
+		if err != nil {
+			return
+		}
+		v.args = append(v.args, argDecl{decl, c.SourceLocation})
+		args = []string{decl.Default}
+	case "ENV", "LET", "SET":
+		if len(args) > 0 {
+			name, _, _ := strings.Cut(args[0], "=")
+			v.envs = append(v.envs, name)
+			args = args[1:]
+		}
+	}
+	v.addRefs(args, c.SourceLocation, !shellCmds.Contains(c.Name))
+}
+
+func (v *varCollector) VisitIf(s spec.IfStatement) {
+	v.addRefs(s.Expression, s.SourceLocation, false)
+	for _, e := range s.ElseIf {
+		v.addRefs(e.Expression, e.SourceLocation, false)
+	}
+}
+
+func (v *varCollector) VisitFor(s spec.ForStatement) {
+	_, rest := earthfile.SplitFlags(s.Args)
+	if len(rest) > 0 {
+		v.envs = append(v.envs, rest[0])
+	}
+	v.addRefs(s.Args, s.SourceLocation, false)
+}
+
+func (v *varCollector) addRefs(args []string, loc *spec.SourceLocation, checked bool) {
+	for _, a := range args {
+		for _, name := range earthfile.ArgRefs(a) {
+			v.refs = append(v.refs, varRef{name: name, loc: loc, checked: checked})
+		}
+	}
+}
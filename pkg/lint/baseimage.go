@@ -0,0 +1,70 @@
+package lint
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"github.com/dorfire/heavenly/pkg/earthfile"
+)
+
+func init() {
+	Register(unpinnedBaseImage{})
+}
+
+// unpinnedBaseImage reports FROM commands of external images by a mutable tag, rather than by digest.
+type unpinnedBaseImage struct{}
+
+type unpinnedBaseImageOptions struct {
+	// Glob patterns of images which may be referenced by tag. Patterns without a tag match image names, like
+	// `golang` or `ghcr.io/acme/*`; patterns with a tag match full references, like `alpine:3.*`.
+	Allow []string `yaml:"allow"`
+}
+
+func (unpinnedBaseImage) Name() string { return "unpinned-base-image" }
+func (unpinnedBaseImage) Doc() string {
+	return "FROM command of an image by mutable tag instead of by digest"
+}
+func (unpinnedBaseImage) DefaultSeverity() Severity { return SeverityWarning }
+
+func (r unpinnedBaseImage) Check(p *Pass) error {
+	var opts unpinnedBaseImageOptions
+	if err := p.DecodeOptions(&opts); err != nil {
+		return err
+	}
+	for _, pattern := range opts.Allow {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid allow pattern %q: %w", pattern, err)
+		}
+	}
+
+	for _, ef := range p.Repo.Earthfiles {
+		for _, img := range earthfile.CollectImages(ef) {
+			if img.Digest() != "" || img.Ref == "scratch" || strings.ContainsRune(img.Ref, '$') || r.allowed(opts, img) {
+				continue
+			}
+
+			p.Reportf(p.Repo.Pos(ef, img.Loc), "base image %s is referenced by mutable tag :%s instead of by @sha256: digest",
+				img.Ref, imageTag(img))
+		}
+	}
+	return nil
+}
+
+func (unpinnedBaseImage) allowed(opts unpinnedBaseImageOptions, img earthfile.ImageRef) bool {
+	return lo.ContainsBy(opts.Allow, func(pattern string) bool {
+		subject := img.Name()
+		if strings.ContainsRune(pattern[strings.LastIndex(pattern, "/")+1:], ':') {
+			subject = img.Name() + ":" + imageTag(img)
+		}
+		ok, _ := path.Match(pattern, subject)
+		return ok
+	})
+}
+
+// imageTag returns the tag of an image reference, which is `latest` if it's implicit.
+func imageTag(img earthfile.ImageRef) string {
+	return lo.Ternary(img.Tag() == "", "latest", img.Tag())
+}
@@ -0,0 +1,51 @@
+package lint
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/samber/lo"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds per-rule lint settings, keyed by rule name.
+type Config struct {
+	Rules map[string]RuleConfig `yaml:"rules"`
+}
+
+type RuleConfig struct {
+	Enabled  *bool     `yaml:"enabled"`  // Defaults to true
+	Severity *Severity `yaml:"severity"` // Defaults to the rule's DefaultSeverity
+	Options  yaml.Node `yaml:"options"`  // Rule-specific options, decoded by the rule via Pass.DecodeOptions
+}
+
+// decodeOptions decodes the rule-specific options into v, rejecting unknown fields. v is left as is if there are no
+// options.
+func (c RuleConfig) decodeOptions(v any) error {
+	if c.Options.IsZero() {
+		return nil
+	}
+
+	// yaml.Node.Decode can't reject unknown fields, so the options are re-encoded and decoded strictly
+	content, err := yaml.Marshal(&c.Options)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	dec.KnownFields(true)
+	if err = dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+func (c Config) validate(rules []Rule) error {
+	for name := range c.Rules {
+		if !lo.ContainsBy(rules, func(r Rule) bool { return r.Name() == name }) {
+			return fmt.Errorf("lint: config refers to unknown rule %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/earthly/earthly/ast/spec"
+
+	"github.com/dorfire/heavenly/pkg/earthfile"
+	"github.com/dorfire/heavenly/pkg/earthfilefmt"
+)
+
+func init() {
+	Register(copyNonexistentPath{})
+}
+
+// copyNonexistentPath reports COPY sources that match nothing, which Earthly only detects when running the target.
+type copyNonexistentPath struct{}
+
+func (copyNonexistentPath) Name() string { return "copy-nonexistent-path" }
+func (copyNonexistentPath) Doc() string {
+	return "Earthfile COPY command for a nonexistent path"
+}
+func (copyNonexistentPath) DefaultSeverity() Severity { return SeverityError }
+
+func (r copyNonexistentPath) Check(p *Pass) error {
+	for _, ef := range p.Repo.Earthfiles {
+		recipes(ef, func(_ *spec.Target, recipe spec.Block) {
+			for _, c := range commands(recipe) {
+				if c.Name == "COPY" {
+					r.checkCopy(p, ef, c)
+				}
+			}
+		})
+	}
+	return nil
+}
+
+func (copyNonexistentPath) checkCopy(p *Pass, ef *earthfile.Earthfile, c spec.Command) {
+	pos := p.Repo.Pos(ef, c.SourceLocation)
+	args, err := earthfile.ParseCopyArgs(c.Args)
+	if err != nil {
+		p.Reportf(pos, "%v", err)
+		return
+	}
+	if earthfile.HasFlag(args.Flags, "--if-exists") {
+		return
+	}
+
+	var missing []Diagnostic
+	existing := make([]string, 0, len(args.Sources))
+	for _, rawSrc := range args.Sources {
+		existing = append(existing, rawSrc)
+		src := ef.ExpandArgs(earthfile.UnwrapArtifactRef(rawSrc))
+		if strings.ContainsRune(src, '$') {
+			continue // Depends on an ARG that can't be resolved statically
+		}
+
+		if strings.ContainsRune(src, '+') {
+			continue // Target artifacts are checked by dangling-target-ref
+		}
+
+		exists, err := pathExists(filepath.Join(ef.Dir, src))
+		if err != nil {
+			p.Reportf(pos, "could not check COPY source %q: %v", src, err)
+		} else if !exists {
+			existing = existing[:len(existing)-1]
+			missing = append(missing, Diagnostic{Pos: pos, Message: fmt.Sprintf("COPY source %q matches no file or directory", src)})
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	// The fix of the whole command is attached to its first diagnostic, since fixes of the same lines can't be combined
+	if c.SourceLocation != nil && len(existing) == 0 {
+		missing[0].Fixes = []TextEdit{p.Repo.deleteCmdEdit(ef, c.SourceLocation)}
+	} else if c.SourceLocation != nil {
+		args.Sources = existing
+		fixed := earthfilefmt.FormatCmd(c.Name, args.Args())
+		missing[0].Fixes = []TextEdit{p.Repo.replaceCmdEdit(ef, c.SourceLocation, fixed)}
+	}
+	for _, d := range missing {
+		p.Report(d)
+	}
+}
+
+// pathExists returns whether p, which may be a glob pattern, matches any file or directory.
+func pathExists(p string) (bool, error) {
+	if strings.ContainsAny(p, "*?[") {
+		matches, err := filepath.Glob(p)
+		return len(matches) > 0, err
+	}
+	_, err := os.Lstat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,90 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/earthly/earthly/ast/spec"
+	"github.com/samber/lo"
+
+	"github.com/dorfire/heavenly/pkg/dartdepresolver"
+	"github.com/dorfire/heavenly/pkg/earthfile"
+	"github.com/dorfire/heavenly/pkg/earthfilefmt"
+)
+
+var (
+	dartBuildCmdRe = regexp.MustCompile(`\b(dart|flutter)\s+(pub|build|test|analyze|run|compile)\b`)
+)
+
+func init() {
+	Register(dartImportWithoutCopy{})
+}
+
+// dartImportWithoutCopy reports Dart/Flutter-building targets which lack COPY commands that `heavenly dartcopies`
+// would generate for the Dart package in their dir. `pub get` fails without any of them, including transitive ones.
+type dartImportWithoutCopy struct{}
+
+func (dartImportWithoutCopy) Name() string { return "dart-import-without-copy" }
+func (dartImportWithoutCopy) Doc() string {
+	return "Dart package import without a corresponding COPY command"
+}
+func (dartImportWithoutCopy) DefaultSeverity() Severity { return SeverityError }
+
+func (dartImportWithoutCopy) Check(p *Pass) error {
+	for _, ef := range p.Repo.Earthfiles {
+		if !dartdepresolver.HasPubspec(ef.Dir) {
+			continue
+		}
+
+		for i := range ef.Spec.Targets {
+			t := &ef.Spec.Targets[i]
+			if !runsDart(t.Recipe) {
+				continue
+			}
+
+			pos := p.Repo.Pos(ef, t.SourceLocation)
+			missing, err := missingDartCopies(p.Repo, ef, t)
+			if err != nil {
+				p.Reportf(pos, "could not resolve Dart dependencies of target +%s: %v", t.Name, err)
+				continue
+			}
+			for _, c := range missing {
+				line := earthfilefmt.FormatCmd(c.Name, c.Args)
+				p.Report(Diagnostic{
+					Pos:     pos,
+					Message: fmt.Sprintf("target +%s lacks `%s`, which its Dart dependencies need", t.Name, line),
+					Fixes:   []TextEdit{p.Repo.insertCmdsEdit(ef, t, line)},
+				})
+			}
+		}
+	}
+	return nil
+}
+
+func missingDartCopies(repo *Repo, ef *earthfile.Earthfile, t *spec.Target) ([]spec.Command, error) {
+	res, err := dartdepresolver.New(ef.Dir, repo.Log)
+	if err != nil {
+		return nil, err
+	}
+
+	copies, devCopies, err := res.ResolveImportsToCopyCommands(ef.Dir, true)
+	if err != nil {
+		return nil, err
+	}
+	// `pub get` resolves dev dependencies too
+	copies = append(copies, devCopies...)
+
+	topDir := res.ProjectRoot()
+	actual := targetCopySrcKeys(ef, t, topDir)
+	return lo.Filter(copies, func(c spec.Command, _ int) bool {
+		e := copySrcKey(ef.Dir, topDir, copySources(c)[0])
+		return !lo.ContainsBy(actual, func(a string) bool { return copySrcCovers(a, e) })
+	}), nil
+}
+
+func runsDart(recipe spec.Block) bool {
+	return lo.ContainsBy(commands(recipe), func(c spec.Command) bool {
+		return c.Name == "RUN" && dartBuildCmdRe.MatchString(strings.Join(c.Args, " "))
+	})
+}
@@ -0,0 +1,106 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+)
+
+const diffContext = 3
+
+// UnifiedDiff returns a unified diff of the lines of a fixed file, or "" if it's unchanged.
+func UnifiedDiff(f FixedFile) string {
+	ops := diffLines(f.Before, f.After)
+
+	b := new(strings.Builder)
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// Extend the hunk while changes are within twice the context of each other
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			} else if j-end >= 2*diffContext {
+				break
+			}
+		}
+		end += diffContext
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		if b.Len() == 0 {
+			fmt.Fprintf(b, "--- a/%s\n+++ b/%s\n", f.Path, f.Path)
+		}
+		writeHunk(b, ops[start:end])
+		i = end
+	}
+	return b.String()
+}
+
+type diffOp struct {
+	kind         byte // ' ', '-' or '+'
+	line         string
+	aLine, bLine int // 1-based line numbers before and after the op
+}
+
+func writeHunk(b *strings.Builder, ops []diffOp) {
+	aCount, bCount := 0, 0
+	for _, o := range ops {
+		if o.kind != '+' {
+			aCount++
+		}
+		if o.kind != '-' {
+			bCount++
+		}
+	}
+	fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", ops[0].aLine, aCount, ops[0].bLine, bCount)
+	for _, o := range ops {
+		fmt.Fprintf(b, "%c%s\n", o.kind, o.line)
+	}
+}
+
+// diffLines computes a line-level edit script from a to b via their longest common subsequence.
+func diffLines(a, b []string) []diffOp {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var res []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			res = append(res, diffOp{' ', a[i], i + 1, j + 1})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			res = append(res, diffOp{'+', b[j], i + 1, j + 1})
+			j++
+		default:
+			res = append(res, diffOp{'-', a[i], i + 1, j + 1})
+			i++
+		}
+	}
+	return res
+}
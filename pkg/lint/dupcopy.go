@@ -0,0 +1,49 @@
+package lint
+
+import (
+	"github.com/earthly/earthly/ast/spec"
+
+	"github.com/dorfire/heavenly/pkg/earthfilefmt"
+)
+
+func init() {
+	Register(duplicateCopy{})
+}
+
+// duplicateCopy reports COPY commands which repeat an earlier one in the same contiguous run of COPY commands; the
+// runs `heavenly fmt` dedupes.
+type duplicateCopy struct{}
+
+func (duplicateCopy) Name() string { return "duplicate-copy" }
+func (duplicateCopy) Doc() string {
+	return "duplicate COPY commands"
+}
+func (duplicateCopy) DefaultSeverity() Severity { return SeverityWarning }
+
+func (duplicateCopy) Check(p *Pass) error {
+	for _, ef := range p.Repo.Earthfiles {
+		recipes(ef, func(_ *spec.Target, recipe spec.Block) {
+			blocks(recipe, func(b spec.Block) {
+				seen := map[string]bool{} // Formatted COPY commands in the current run
+				for _, s := range b {
+					if s.Command == nil || s.Command.Name != "COPY" {
+						seen = map[string]bool{}
+						continue
+					}
+
+					line := earthfilefmt.FormatCmd(s.Command.Name, s.Command.Args)
+					if !seen[line] || s.Command.SourceLocation == nil {
+						seen[line] = true
+						continue
+					}
+					p.Report(Diagnostic{
+						Pos:     p.Repo.Pos(ef, s.Command.SourceLocation),
+						Message: "`" + line + "` duplicates a preceding COPY command",
+						Fixes:   []TextEdit{p.Repo.deleteCmdEdit(ef, s.Command.SourceLocation)},
+					})
+				}
+			})
+		})
+	}
+	return nil
+}
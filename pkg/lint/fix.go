@@ -0,0 +1,180 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/earthly/earthly/ast/spec"
+	"github.com/samber/lo"
+
+	"github.com/dorfire/heavenly/pkg/earthfile"
+)
+
+const (
+	defaultIndent = "    "
+)
+
+// TextEdit replaces the lines [Line, Line+Delete) of a file with Insert.
+// With Delete == 0, Insert is inserted before Line.
+type TextEdit struct {
+	File   string   `json:"file"` // Slash-separated path, relative to the repo root
+	Line   int      `json:"line"` // 1-based
+	Delete int      `json:"delete"`
+	Insert []string `json:"insert,omitempty"`
+}
+
+func (e TextEdit) end() int {
+	return e.Line + e.Delete
+}
+
+// FixedFile is the content of a file after applying fixes to it.
+type FixedFile struct {
+	Path          string // Slash-separated path, relative to the repo root
+	Before, After []string
+}
+
+// ApplyFixes applies the fixes of the given diagnostics to the files they refer to, in memory.
+// Fixes which overlap previously applied ones are skipped. It returns the fixed files, and the number of diagnostics
+// whose fixes were applied.
+func (r *Repo) ApplyFixes(diags []Diagnostic) ([]FixedFile, int, error) {
+	editsByFile := map[string][]TextEdit{}
+	fixable := 0
+	for _, d := range diags {
+		if len(d.Fixes) == 0 || !r.fixesApplicable(editsByFile, d.Fixes) {
+			continue
+		}
+		for _, e := range d.Fixes {
+			editsByFile[e.File] = append(editsByFile[e.File], e)
+		}
+		fixable++
+	}
+
+	res := make([]FixedFile, 0, len(editsByFile))
+	for path, edits := range editsByFile {
+		before, err := r.Source(filepath.Join(r.Root, filepath.FromSlash(path)))
+		if err != nil {
+			return nil, 0, err
+		}
+		res = append(res, FixedFile{Path: path, Before: before, After: applyEdits(before, edits)})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Path < res[j].Path })
+	return res, fixable, nil
+}
+
+// WriteFixes writes fixed files back to the repo.
+func (r *Repo) WriteFixes(files []FixedFile) error {
+	for _, f := range files {
+		p := filepath.Join(r.Root, filepath.FromSlash(f.Path))
+		fi, err := os.Stat(p)
+		if err != nil {
+			return fmt.Errorf("lint: could not write fixes to %s: %w", f.Path, err)
+		}
+		if err = os.WriteFile(p, []byte(strings.Join(f.After, "\n")), fi.Mode().Perm()); err != nil {
+			return fmt.Errorf("lint: could not write fixes to %s: %w", f.Path, err)
+		}
+	}
+	return nil
+}
+
+// fixesApplicable returns whether none of the given edits overlap with the already accepted ones.
+func (r *Repo) fixesApplicable(accepted map[string][]TextEdit, edits []TextEdit) bool {
+	for _, e := range edits {
+		for _, a := range accepted[e.File] {
+			if e.Delete > 0 && a.Delete > 0 && e.Line < a.end() && a.Line < e.end() {
+				return false
+			}
+			// An insertion within a deleted range has nowhere to go
+			if e.Delete == 0 && a.Line < e.Line && e.Line < a.end() || a.Delete == 0 && e.Line < a.Line && a.Line < e.end() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func applyEdits(lines []string, edits []TextEdit) []string {
+	// Insertions before a line go before deletions starting at it; otherwise the order of edits is kept
+	// Rules may suggest the same edit for several diagnostics, e.g. inserting a COPY several targets need
+	edits = lo.UniqBy(edits, func(e TextEdit) string { return fmt.Sprintf("%d %d %q", e.Line, e.Delete, e.Insert) })
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].Line != edits[j].Line {
+			return edits[i].Line < edits[j].Line
+		}
+		return edits[i].Delete < edits[j].Delete
+	})
+
+	res := make([]string, 0, len(lines))
+	next := 1 // Next line of the original file to copy
+	for _, e := range edits {
+		for ; next < e.Line && next <= len(lines); next++ {
+			res = append(res, lines[next-1])
+		}
+		res = append(res, e.Insert...)
+		next = lo.Max([]int{next, e.end()})
+	}
+	for ; next <= len(lines); next++ {
+		res = append(res, lines[next-1])
+	}
+	return res
+}
+
+// Source returns the lines of a file in the repo.
+func (r *Repo) Source(path string) ([]string, error) {
+	if lines, ok := r.sources[path]; ok {
+		return lines, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(content), "\n")
+	r.sources[path] = lines
+	return lines, nil
+}
+
+// deleteCmdEdit deletes the lines of a command.
+func (r *Repo) deleteCmdEdit(ef *earthfile.Earthfile, loc *spec.SourceLocation) TextEdit {
+	return TextEdit{File: r.RelPath(ef.Path), Line: loc.StartLine, Delete: loc.EndLine - loc.StartLine + 1}
+}
+
+// replaceCmdEdit replaces the lines of a command with the given one, keeping its indentation.
+func (r *Repo) replaceCmdEdit(ef *earthfile.Earthfile, loc *spec.SourceLocation, cmd string) TextEdit {
+	e := r.deleteCmdEdit(ef, loc)
+	e.Insert = []string{r.indentOf(ef, loc.StartLine) + cmd}
+	return e
+}
+
+// insertCmdsEdit inserts commands to a target, after its last COPY command, or after its first FROM command, or at
+// the beginning of its recipe.
+func (r *Repo) insertCmdsEdit(ef *earthfile.Earthfile, t *spec.Target, cmds ...string) TextEdit {
+	line, indent := t.SourceLocation.StartLine+1, defaultIndent
+	for i, s := range t.Recipe {
+		if s.SourceLocation == nil {
+			continue
+		}
+		if i == 0 {
+			indent = r.indentOf(ef, s.SourceLocation.StartLine)
+		}
+		if s.Command != nil && (s.Command.Name == "COPY" || i == 0 && s.Command.Name == "FROM") {
+			line = s.SourceLocation.EndLine + 1
+		}
+	}
+
+	return TextEdit{
+		File:   r.RelPath(ef.Path),
+		Line:   line,
+		Insert: lo.Map(cmds, func(c string, _ int) string { return indent + c }),
+	}
+}
+
+func (r *Repo) indentOf(ef *earthfile.Earthfile, line int) string {
+	lines, err := r.Source(ef.Path)
+	if err != nil || line > len(lines) {
+		return defaultIndent
+	}
+	l := lines[line-1]
+	return l[:len(l)-len(strings.TrimLeft(l, " \t"))]
+}
@@ -0,0 +1,394 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/earthly/earthly/ast/spec"
+	"github.com/samber/lo"
+
+	"github.com/dorfire/heavenly/pkg/earthfile"
+	"github.com/dorfire/heavenly/pkg/earthfilefmt"
+	"github.com/dorfire/heavenly/pkg/godepresolver"
+)
+
+var (
+	goBuildCmdRe = regexp.MustCompile(`\bgo\s+(build|install|run|test|vet|generate)\b`)
+	goTestCmdRe  = regexp.MustCompile(`\bgo\s+test\b`)
+)
+
+func init() {
+	Register(goImportWithoutCopy{})
+	Register(goCopyWithoutImport{})
+}
+
+// goImportWithoutCopy reports Go-building targets which lack COPY commands that `heavenly gocopies` would generate for
+// the Go package in their dir.
+type goImportWithoutCopy struct{}
+
+func (goImportWithoutCopy) Name() string { return "go-import-without-copy" }
+func (goImportWithoutCopy) Doc() string {
+	return "Go package import without a corresponding COPY command"
+}
+func (goImportWithoutCopy) DefaultSeverity() Severity { return SeverityError }
+
+func (goImportWithoutCopy) Check(p *Pass) error {
+	targets, err := p.Repo.goTargetCopies()
+	if err != nil {
+		return err
+	}
+	lackedLines := map[string]map[string]bool{} // Target key -> lacked COPY lines
+	for _, t := range targets {
+		lackedLines[t.key()] = lo.SliceToMap(t.missing, func(c spec.Command) (string, bool) {
+			return earthfilefmt.FormatCmd(c.Name, c.Args), true
+		})
+	}
+
+	for _, t := range targets {
+		pos := p.Repo.Pos(t.ef, t.target.SourceLocation)
+		if t.err != nil {
+			p.Reportf(pos, "could not resolve Go imports of target +%s: %v", t.target.Name, t.err)
+			continue
+		}
+		for _, c := range t.missing {
+			line := earthfilefmt.FormatCmd(c.Name, c.Args)
+			d := Diagnostic{
+				Pos:     pos,
+				Message: fmt.Sprintf("target +%s lacks `%s`, which its Go imports need", t.target.Name, line),
+			}
+			// Adding the COPY to a target this one is based on fixes both
+			if !lo.ContainsBy(fromChain(t.ef, t.target)[1:], func(ft earthfileTarget) bool {
+				return lackedLines[ft.key()][line]
+			}) {
+				d.Fixes = []TextEdit{p.Repo.insertCmdsEdit(t.ef, t.target, line)}
+			}
+			p.Report(d)
+		}
+	}
+	return nil
+}
+
+// goCopyWithoutImport reports COPY commands of internal Go package sources which the Go package built by the target
+// does not import.
+type goCopyWithoutImport struct{}
+
+func (goCopyWithoutImport) Name() string { return "go-copy-without-import" }
+func (goCopyWithoutImport) Doc() string {
+	return "COPY command of an internal Go package which nothing imports"
+}
+func (goCopyWithoutImport) DefaultSeverity() Severity { return SeverityWarning }
+
+func (goCopyWithoutImport) Check(p *Pass) error {
+	targets, err := p.Repo.goTargetCopies()
+	if err != nil {
+		return err
+	}
+	for _, t := range targets {
+		for _, c := range t.unused {
+			d := Diagnostic{
+				Pos:     p.Repo.Pos(t.ef, c.SourceLocation),
+				Message: fmt.Sprintf("target +%s copies Go package sources its Go imports don't need", t.target.Name),
+			}
+			// COPYs with other sources may still be needed, and so may COPYs in targets others are based on
+			if len(copySources(c)) == 1 && c.SourceLocation != nil && !p.Repo.isFromBase(t.earthfileTarget) {
+				d.Fixes = []TextEdit{p.Repo.deleteCmdEdit(t.ef, c.SourceLocation)}
+			}
+			p.Report(d)
+		}
+	}
+	return nil
+}
+
+// goTargetCopies is the result of cross-checking the COPY commands of a Go-building target with its Go imports.
+type goTargetCopies struct {
+	earthfileTarget
+	missing []spec.Command // COPY commands the target's Go imports need, but which it lacks
+	unused  []spec.Command // COPY commands in the target of Go package sources which no Go imports need
+	err     error          // Set if the target's Go imports could not be resolved
+
+	topDir   string   // The root of the target's Go project
+	expected []string // Keys of the COPY sources the target's Go imports need
+}
+
+// goTargetCopies cross-checks all Go-building targets in the repo with the COPY commands that
+// godepresolver.ResolveImportsToCopyCommands resolves for the Go packages in and below their Earthfile's dir.
+func (r *Repo) goTargetCopies() ([]goTargetCopies, error) {
+	if r.goCopies != nil {
+		return r.goCopies, nil
+	}
+
+	r.goCopies = []goTargetCopies{}
+	resolvers := map[string]*godepresolver.GoDepResolver{} // go.mod dir -> resolver
+	for _, ef := range r.Earthfiles {
+		goModDir := findGoModDir(ef.Dir, r.Root)
+		if goModDir == "" {
+			continue
+		}
+
+		for i := range ef.Spec.Targets {
+			t := &ef.Spec.Targets[i]
+			runsGo, runsGoTest := goCommandsIn(t.Recipe)
+			if !runsGo {
+				continue
+			}
+
+			res, ok := resolvers[goModDir]
+			if !ok {
+				var err error
+				if res, err = godepresolver.New(goModDir, r.Log); err != nil {
+					r.Log.DebugPrintf("Skipping Go import checks of %s: %v", r.RelPath(goModDir), err)
+				}
+				resolvers[goModDir] = res
+			}
+			if res == nil {
+				continue
+			}
+
+			r.goCopies = append(r.goCopies, crossCheckGoCopies(res, ef, t, runsGoTest))
+		}
+	}
+
+	// A COPY command in a target may be needed by the Go imports of the targets based on it, e.g. a test target which
+	// is FROM the build target
+	for i := range r.goCopies {
+		t := &r.goCopies[i]
+		if t.err != nil {
+			continue
+		}
+		needed := t.expected
+		for _, o := range r.goCopies {
+			isBase := func(ft earthfileTarget) bool { return ft.key() == t.key() }
+			if lo.ContainsBy(fromChain(o.ef, o.target)[1:], isBase) {
+				needed = append(needed, o.expected...)
+			}
+		}
+		t.unused = unusedGoCopies(t.ef, t.target, t.topDir, needed)
+	}
+	return r.goCopies, nil
+}
+
+func crossCheckGoCopies(res *godepresolver.GoDepResolver, ef *earthfile.Earthfile, t *spec.Target, test bool) goTargetCopies {
+	result := goTargetCopies{earthfileTarget: earthfileTarget{ef, t}}
+
+	copies, testCopies, err := res.ResolveImportsToCopyCommands(ef.Dir, false)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	if test {
+		copies = append(copies, testCopies...)
+	}
+
+	result.topDir = res.ProjectRoot()
+	result.expected = lo.Map(copies, func(c spec.Command, _ int) string {
+		return copySrcKey(ef.Dir, result.topDir, copySources(c)[0])
+	})
+
+	actual := targetCopySrcKeys(ef, t, result.topDir)
+	// Plain-path COPY commands, like `COPY . .` in the Earthfile at the root of the Go project, cover the packages in
+	// their dirs
+	copiedDirs := targetCopyPaths(ef, t)
+	for i, e := range result.expected {
+		if lo.ContainsBy(actual, func(a string) bool { return copySrcCovers(a, e) }) {
+			continue
+		}
+		pkgDir, _, _ := strings.Cut(e, "+")
+		if lo.ContainsBy(copiedDirs, func(d string) bool { return pathContains(d, pkgDir) }) {
+			continue
+		}
+		result.missing = append(result.missing, copies[i])
+	}
+
+	return result
+}
+
+// unusedGoCopies returns the COPY commands in the given target of Go package sources which are not covered by the
+// given needed COPY source keys.
+func unusedGoCopies(ef *earthfile.Earthfile, t *spec.Target, topDir string, needed []string) []spec.Command {
+	var res []spec.Command
+	for _, c := range commands(t.Recipe) {
+		if c.Name != "COPY" {
+			continue
+		}
+		for _, src := range copySources(c) {
+			k := copySrcKey(ef.Dir, topDir, ef.ExpandArgs(src))
+			if k == "" || k == selfCopySrcKey(ef.Dir) || !isGoPackageSrc(k) {
+				continue
+			}
+			if !lo.ContainsBy(needed, func(e string) bool { return copySrcCovers(k, e) }) {
+				res = append(res, c)
+				break
+			}
+		}
+	}
+	return res
+}
+
+// targetCopySrcKeys returns the keys of the COPY sources in the given target and in the targets it's based on.
+func targetCopySrcKeys(ef *earthfile.Earthfile, t *spec.Target, topDir string) []string {
+	var res []string
+	for _, ft := range fromChain(ef, t) {
+		for _, c := range commands(ft.target.Recipe) {
+			if c.Name != "COPY" {
+				continue
+			}
+			for _, src := range copySources(c) {
+				res = append(res, copySrcKey(ft.ef.Dir, topDir, ft.ef.ExpandArgs(src)))
+			}
+		}
+	}
+	return res
+}
+
+// targetCopyPaths returns the absolute paths of the plain-path COPY sources in the given target and in the targets
+// it's based on.
+func targetCopyPaths(ef *earthfile.Earthfile, t *spec.Target) []string {
+	var res []string
+	for _, ft := range fromChain(ef, t) {
+		for _, c := range commands(ft.target.Recipe) {
+			if c.Name != "COPY" {
+				continue
+			}
+			for _, src := range copySources(c) {
+				src = ft.ef.ExpandArgs(src)
+				if earthfile.IsLocalTargetRef(earthfile.UnwrapArtifactRef(src)) || strings.ContainsRune(src, '$') {
+					continue
+				}
+				res = append(res, filepath.Join(ft.ef.Dir, src))
+			}
+		}
+	}
+	return res
+}
+
+// pathContains returns whether the given path is dir or is below it.
+func pathContains(dir, path string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+type earthfileTarget struct {
+	ef     *earthfile.Earthfile
+	target *spec.Target
+}
+
+func (t earthfileTarget) key() string {
+	return t.ef.Path + "+" + t.target.Name
+}
+
+// isFromBase returns whether any target in the repo is based on the given one via FROM commands.
+func (r *Repo) isFromBase(base earthfileTarget) bool {
+	for _, ef := range r.Earthfiles {
+		for i := range ef.Spec.Targets {
+			chain := fromChain(ef, &ef.Spec.Targets[i])
+			if lo.ContainsBy(chain[1:], func(ft earthfileTarget) bool { return ft.key() == base.key() }) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fromChain returns the given target, followed by the local targets it is based on via FROM commands.
+// Unresolvable FROM targets are skipped.
+func fromChain(ef *earthfile.Earthfile, t *spec.Target) []earthfileTarget {
+	res := []earthfileTarget{{ef, t}}
+	seen := map[string]bool{res[0].key(): true}
+	for i := 0; i < len(res); i++ {
+		cur := res[i]
+		for _, c := range commands(cur.target.Recipe) {
+			if c.Name != "FROM" {
+				continue
+			}
+			call, err := earthfile.ParseTargetCall(c.Args)
+			if err != nil || !earthfile.IsLocalTargetRef(call.Target) {
+				continue
+			}
+			fromEf, fromT, err := cur.ef.Target(cur.ef.ExpandArgs(call.Target))
+			if err != nil {
+				continue
+			}
+			ft := earthfileTarget{fromEf, fromT}
+			if seen[ft.key()] {
+				continue
+			}
+			seen[ft.key()] = true
+			res = append(res, ft)
+		}
+	}
+	return res
+}
+
+func goCommandsIn(recipe spec.Block) (runsGo, runsGoTest bool) {
+	for _, c := range commands(recipe) {
+		if c.Name != "RUN" {
+			continue
+		}
+		line := strings.Join(c.Args, " ")
+		runsGo = runsGo || goBuildCmdRe.MatchString(line)
+		runsGoTest = runsGoTest || goTestCmdRe.MatchString(line)
+	}
+	return runsGo, runsGoTest
+}
+
+func copySources(c spec.Command) []string {
+	args, err := earthfile.ParseCopyArgs(c.Args)
+	if err != nil {
+		return nil
+	}
+	return args.Sources
+}
+
+// copySrcKey canonicalizes a COPY source which references a local target artifact, relative to the given dir; e.g.
+// `$TOP/libs/+src/foo/*` -> `/abs/path/to/libs+src/foo/*`. It returns "" for other sources.
+func copySrcKey(dir, topDir, src string) string {
+	src = strings.ReplaceAll(earthfile.UnwrapArtifactRef(src), "$TOP", topDir)
+	if !earthfile.IsLocalTargetRef(src) {
+		return ""
+	}
+	target, artifact := earthfile.SplitArtifactRef(src)
+	targetDir, targetName, _ := strings.Cut(target, "+")
+	if !filepath.IsAbs(targetDir) {
+		targetDir = filepath.Join(dir, targetDir)
+	}
+	return filepath.Clean(targetDir) + "+" + targetName + artifact
+}
+
+func selfCopySrcKey(dir string) string {
+	return filepath.Clean(dir) + "+src/*"
+}
+
+// copySrcCovers returns whether the COPY source with key a includes the one with key b; e.g. `x+src/*` covers
+// `x+src/foo/*`.
+func copySrcCovers(a, b string) bool {
+	return a != "" && (a == b || strings.HasSuffix(a, "/*") && strings.HasPrefix(b, strings.TrimSuffix(a, "*")))
+}
+
+// isGoPackageSrc returns whether a COPY source key references the Go files of a package, e.g. `/libs+src/foo/*`.
+func isGoPackageSrc(key string) bool {
+	target, artifact := earthfile.SplitArtifactRef(key)
+	dir, name, _ := strings.Cut(target, "+")
+	if name != "src" {
+		return false
+	}
+	ents, err := os.ReadDir(filepath.Join(dir, strings.TrimSuffix(artifact, "*")))
+	if err != nil {
+		return false
+	}
+	return lo.ContainsBy(ents, func(e os.DirEntry) bool { return !e.IsDir() && strings.HasSuffix(e.Name(), ".go") })
+}
+
+// findGoModDir returns the closest dir in or above dir, up to root, which contains a go.mod file; or "".
+func findGoModDir(dir, root string) string {
+	for d := dir; strings.HasPrefix(d, root); d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return d
+		}
+		if d == root {
+			break
+		}
+	}
+	return ""
+}
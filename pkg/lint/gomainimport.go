@@ -0,0 +1,42 @@
+package lint
+
+import (
+	mapset "github.com/deckarep/golang-set/v2"
+)
+
+func init() {
+	Register(goMainImport{})
+}
+
+// goMainImport reports Go files, including tests, that import a `main` package. Such imports don't compile, except in
+// the external tests of the `main` package itself.
+type goMainImport struct{}
+
+func (goMainImport) Name() string              { return "go-main-import" }
+func (goMainImport) Doc() string               { return "Go code that imports a Go `main` package" }
+func (goMainImport) DefaultSeverity() Severity { return SeverityError }
+
+func (goMainImport) Check(p *Pass) error {
+	pkgs, err := p.Repo.GoPackages()
+	if err != nil {
+		return err
+	}
+
+	mains := mapset.NewThreadUnsafeSet[string]()
+	for _, pkg := range pkgs {
+		if pkg.Name == "main" {
+			mains.Add(pkg.ImportPath)
+		}
+	}
+
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			for _, i := range f.Imports {
+				if mains.Contains(i.Path) && !(f.Test && i.Path == pkg.ImportPath) {
+					p.Reportf(p.Repo.GoPos(i.Pos), "%s imports %s, which is a `main` package", pkg.ImportPath, i.Path)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package lint
+
+import (
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/earthly/earthly/ast/spec"
+	"github.com/samber/lo"
+
+	"github.com/dorfire/heavenly/pkg/earthdir"
+	"github.com/dorfire/heavenly/pkg/earthfile"
+	"github.com/dorfire/heavenly/pkg/goparse"
+)
+
+func init() {
+	Register(goPackageWithoutSrc{})
+}
+
+// goPackageWithoutSrc reports Go package dirs whose closest Earthfile has no +src target that copies them.
+// `heavenly gocopies` presumes that layout, and would generate COPY commands of nonexistent target artifacts otherwise.
+type goPackageWithoutSrc struct{}
+
+func (goPackageWithoutSrc) Name() string { return "go-package-without-src" }
+func (goPackageWithoutSrc) Doc() string {
+	return "Go package directory without a corresponding +src target"
+}
+func (goPackageWithoutSrc) DefaultSeverity() Severity { return SeverityWarning }
+
+func (goPackageWithoutSrc) Check(p *Pass) error {
+	pkgs, err := p.Repo.GoPackages()
+	if err != nil {
+		return err
+	}
+
+	for _, pkg := range pkgs {
+		if reason := p.Repo.srcCoverage(pkg.Dir); reason != "" {
+			p.Reportf(p.Repo.GoPos(packageClausePos(pkg)), "Go package dir %s is not covered by a +src target: %s",
+				p.Repo.RelPath(pkg.Dir), reason)
+		}
+	}
+	return nil
+}
+
+// srcCoverage returns why the given dir is not copied by the +src target of its closest Earthfile, or "" if it is.
+func (r *Repo) srcCoverage(dir string) string {
+	// InOrAbove doesn't check upToDir itself, so pass the repo root's parent to consider the top-level Earthfile
+	earthDir, err := earthdir.InOrAbove(dir, filepath.Dir(r.Root), true)
+	if err != nil {
+		return "no Earthfile in or above it"
+	}
+
+	ef, ok := lo.Find(r.Earthfiles, func(ef *earthfile.Earthfile) bool { return ef.Dir == earthDir })
+	if !ok {
+		return fmt.Sprintf("%s could not be parsed", r.RelPath(filepath.Join(earthDir, "Earthfile")))
+	}
+
+	src, ok := lo.Find(ef.Spec.Targets, func(t spec.Target) bool { return t.Name == "src" })
+	if !ok {
+		return fmt.Sprintf("%s has no +src target", r.RelPath(ef.Path))
+	}
+
+	for _, c := range commands(src.Recipe) {
+		if c.Name != "COPY" {
+			continue
+		}
+		for _, s := range copySources(c) {
+			s = ef.ExpandArgs(s)
+			if !strings.ContainsRune(s, '+') && copySrcIncludesDir(filepath.Join(ef.Dir, s), ef.Dir, dir) {
+				return ""
+			}
+		}
+	}
+	return fmt.Sprintf("the +src target in %s does not COPY it", r.RelPath(ef.Path))
+}
+
+// copySrcIncludesDir returns whether a COPY source path, relative to base, includes dir or its files.
+func copySrcIncludesDir(src, base, dir string) bool {
+	if !strings.ContainsAny(src, "*?[") {
+		return dir == src || strings.HasPrefix(dir, src+string(filepath.Separator))
+	}
+
+	// A glob may match the dir, one of its parents, or its files
+	for d := dir; strings.HasPrefix(d, base); d = filepath.Dir(d) {
+		if ok, _ := filepath.Match(src, d); ok {
+			return true
+		}
+		if d == base {
+			break
+		}
+	}
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	return lo.ContainsBy(ents, func(e os.DirEntry) bool {
+		ok, _ := filepath.Match(src, filepath.Join(dir, e.Name()))
+		return ok && !e.IsDir()
+	})
+}
+
+// packageClausePos returns the position of the package clause in the package's first non-test file, if any.
+func packageClausePos(pkg goparse.Package) token.Position {
+	f, ok := lo.Find(pkg.Files, func(f goparse.File) bool { return !f.Test })
+	if !ok {
+		f = pkg.Files[0]
+	}
+	return f.PackagePos
+}
@@ -0,0 +1,124 @@
+package lint
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/earthly/earthly/ast/spec"
+	"github.com/samber/lo"
+
+	"github.com/dorfire/heavenly/pkg/earthfile"
+	"github.com/dorfire/heavenly/pkg/earthfilefmt"
+)
+
+var (
+	depDownloadCmdRe = regexp.MustCompile(`\b(go\s+mod\s+download|npm\s+(ci|install)|yarn\s+install|` +
+		`pnpm\s+(i|install)|pip3?\s+install\s.*-r\b|poetry\s+install|bundle\s+install|cargo\s+fetch|` +
+		`(dart|flutter)\s+pub\s+get)\b`)
+
+	// COPY sources which copy all sources of a dir
+	broadCopySrcs = mapset.NewThreadUnsafeSet(".", "./", "*", "./*", "+src/*")
+	goModFiles    = mapset.NewThreadUnsafeSet("go.mod", "go.sum")
+)
+
+func init() {
+	Register(cacheHostileLayerOrder{})
+}
+
+// cacheHostileLayerOrder reports broad COPY commands which precede dependency downloads, so that any source change
+// invalidates the cached download layer; and Go-building targets which don't COPY go.mod and go.sum on their own.
+type cacheHostileLayerOrder struct{}
+
+func (cacheHostileLayerOrder) Name() string { return "cache-hostile-layer-order" }
+func (cacheHostileLayerOrder) Doc() string {
+	return "broad COPY command before dependency downloads, which defeats layer caching"
+}
+func (cacheHostileLayerOrder) DefaultSeverity() Severity { return SeverityWarning }
+
+func (r cacheHostileLayerOrder) Check(p *Pass) error {
+	reported := mapset.NewThreadUnsafeSet[Position]() // Broad COPYs in base targets are reported once
+	for _, ef := range p.Repo.Earthfiles {
+		isGoModule := findGoModDir(ef.Dir, p.Repo.Root) != ""
+		for i := range ef.Spec.Targets {
+			t := &ef.Spec.Targets[i]
+			cmds := imageCommands(ef, t, mapset.NewThreadUnsafeSet[string]())
+
+			broad, broadIdx, ok := lo.FindIndexOf(cmds, isBroadCopy)
+			if !ok {
+				continue
+			}
+			pos := p.Repo.Pos(broad.ef, broad.SourceLocation)
+			if reported.Contains(pos) {
+				continue
+			}
+
+			line := earthfilefmt.FormatCmd(broad.Name, broad.Args)
+			if download, ok := lo.Find(cmds[broadIdx:], isDepDownload); ok {
+				reported.Add(pos)
+				p.Reportf(pos, "`%s` precedes `%s`, so any source change invalidates the cached dependency download",
+					line, earthfilefmt.FormatCmd(download.Name, download.Args))
+				continue
+			}
+
+			runsGo, _ := goCommandsIn(t.Recipe)
+			if isGoModule && runsGo && !lo.ContainsBy(cmds[:broadIdx], isGoModCopy) {
+				reported.Add(pos)
+				p.Reportf(pos, "no COPY of only go.mod and go.sum precedes `%s`, so Go modules are downloaded again "+
+					"whenever a source changes", line)
+			}
+		}
+	}
+	return nil
+}
+
+// recipeCmd is a command, along with the Earthfile it resides in.
+type recipeCmd struct {
+	spec.Command
+	ef *earthfile.Earthfile
+}
+
+// imageCommands returns the commands which build the image of a target, in order: the commands of the local target
+// it's last based on via FROM, if any, followed by its own commands since.
+func imageCommands(ef *earthfile.Earthfile, t *spec.Target, seen mapset.Set[string]) []recipeCmd {
+	if !seen.Add(ef.Path + "+" + t.Name) {
+		return nil // A FROM cycle
+	}
+
+	var res []recipeCmd
+	for _, c := range commands(t.Recipe) {
+		if c.Name != "FROM" {
+			res = append(res, recipeCmd{c, ef})
+			continue
+		}
+
+		// FROM replaces the image built so far
+		res = nil
+		call, err := earthfile.ParseTargetCall(c.Args)
+		if err != nil || !earthfile.IsLocalTargetRef(ef.ExpandArgs(call.Target)) {
+			continue
+		}
+		if fromEf, fromT, err := ef.Target(ef.ExpandArgs(call.Target)); err == nil {
+			res = imageCommands(fromEf, fromT, seen)
+		}
+	}
+	return res
+}
+
+func isBroadCopy(c recipeCmd) bool {
+	return c.Name == "COPY" && lo.ContainsBy(copySources(c.Command), func(src string) bool {
+		return broadCopySrcs.Contains(c.ef.ExpandArgs(src))
+	})
+}
+
+func isGoModCopy(c recipeCmd) bool {
+	srcs := copySources(c.Command)
+	return c.Name == "COPY" && len(srcs) > 0 && lo.EveryBy(srcs, func(src string) bool {
+		return goModFiles.Contains(path.Base(c.ef.ExpandArgs(src)))
+	})
+}
+
+func isDepDownload(c recipeCmd) bool {
+	return c.Name == "RUN" && depDownloadCmdRe.MatchString(strings.Join(c.Args, " "))
+}
@@ -0,0 +1,167 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+var severityNames = map[Severity]string{
+	SeverityInfo:    "info",
+	SeverityWarning: "warning",
+	SeverityError:   "error",
+}
+
+func (s Severity) String() string {
+	if n, ok := severityNames[s]; ok {
+		return n
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	for sev, name := range severityNames {
+		if strings.EqualFold(string(text), name) {
+			*s = sev
+			return nil
+		}
+	}
+	return fmt.Errorf("lint: unknown severity %q", text)
+}
+
+// Position is a location in a file in the linted repo.
+type Position struct {
+	File   string `json:"file"`   // Slash-separated path, relative to the repo root
+	Line   int    `json:"line"`   // 1-based; 0 if unknown
+	Column int    `json:"column"` // 1-based; 0 if unknown
+}
+
+func (p Position) String() string {
+	switch {
+	case p.Line == 0:
+		return p.File
+	case p.Column == 0:
+		return fmt.Sprintf("%s:%d", p.File, p.Line)
+	default:
+		return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	}
+}
+
+type Diagnostic struct {
+	Rule     string     `json:"rule"`
+	Severity Severity   `json:"severity"`
+	Pos      Position   `json:"position"`
+	Message  string     `json:"message"`
+	Fixes    []TextEdit `json:"fixes,omitempty"` // Suggested edits which resolve the diagnostic, if it has a mechanical fix
+}
+
+// Rule checks a repo for a single kind of problem.
+type Rule interface {
+	Name() string              // Unique kebab-case name, used in config
+	Doc() string               // One-line description of what the rule detects
+	DefaultSeverity() Severity // Severity of the rule's diagnostics, unless overridden in config
+	Check(p *Pass) error       // Reports diagnostics via p; errors abort the lint run
+}
+
+// Pass holds the state of a single Rule's run over a repo.
+type Pass struct {
+	Repo     *Repo
+	rule     Rule
+	config   RuleConfig
+	severity Severity
+	diags    []Diagnostic
+}
+
+// DecodeOptions decodes the options of the rule in config into v, which should hold their defaults.
+func (p *Pass) DecodeOptions(v any) error {
+	if err := p.config.decodeOptions(v); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	return nil
+}
+
+// Report reports a diagnostic of the rule. Its Rule and Severity are set by the Pass.
+func (p *Pass) Report(d Diagnostic) {
+	d.Rule, d.Severity = p.rule.Name(), p.severity
+	p.diags = append(p.diags, d)
+}
+
+func (p *Pass) Reportf(pos Position, format string, args ...any) {
+	p.Report(Diagnostic{Pos: pos, Message: fmt.Sprintf(format, args...)})
+}
+
+// Run runs all registered rules that are enabled in cfg over the given repo, and returns their diagnostics sorted by
+// position.
+func Run(repo *Repo, cfg Config) ([]Diagnostic, error) {
+	return run(repo, cfg, Rules())
+}
+
+func run(repo *Repo, cfg Config, rules []Rule) ([]Diagnostic, error) {
+	if err := cfg.validate(rules); err != nil {
+		return nil, err
+	}
+
+	var res []Diagnostic
+	passes := map[string]*Pass{} // Rule name -> pass, of rules that ran
+	for _, r := range rules {
+		rc := cfg.Rules[r.Name()]
+		if rc.Enabled != nil && !*rc.Enabled {
+			repo.Log.DebugPrintf("Skipping disabled lint rule %s", r.Name())
+			continue
+		}
+
+		p := &Pass{Repo: repo, rule: r, config: rc, severity: r.DefaultSeverity()}
+		if rc.Severity != nil {
+			p.severity = *rc.Severity
+		}
+
+		repo.Log.DebugPrintf("Running lint rule %s", r.Name())
+		if err := r.Check(p); err != nil {
+			return nil, fmt.Errorf("lint: rule %s failed: %w", r.Name(), err)
+		}
+		res = append(res, p.diags...)
+		passes[r.Name()] = p
+	}
+
+	res, err := repo.applySuppressions(res, passes)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		a, b := res[i].Pos, res[j].Pos
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return res[i].Message < res[j].Message
+	})
+	return res, nil
+}
+
+// HasErrors returns whether any of the given diagnostics is an error.
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/earthly/earthly/conslogging"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+var testLog = conslogging.Current(conslogging.NoColor, conslogging.DefaultPadding, conslogging.Info)
+
+type fakeRule struct{}
+
+func (fakeRule) Name() string              { return "fake" }
+func (fakeRule) Doc() string               { return "reports every Earthfile" }
+func (fakeRule) DefaultSeverity() Severity { return SeverityWarning }
+
+func (fakeRule) Check(p *Pass) error {
+	for _, ef := range p.Repo.Earthfiles {
+		p.Reportf(p.Repo.Pos(ef, ef.Spec.Targets[0].SourceLocation), "target %s", ef.Spec.Targets[0].Name)
+	}
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	repo, err := LoadRepo("testdata/parseerror", testLog)
+	require.NoError(t, err)
+	rules := []Rule{earthfileParseError{}, fakeRule{}}
+
+	diags, err := run(repo, Config{}, rules)
+	require.NoError(t, err)
+	require.Len(t, diags, 2)
+	assert.Equal(t, "earthfile-parse-error", diags[0].Rule)
+	assert.Equal(t, Position{File: "broken/Earthfile"}, diags[0].Pos)
+	assert.Equal(t, Diagnostic{
+		Rule:     "fake",
+		Severity: SeverityWarning,
+		Pos:      Position{File: "ok/Earthfile", Line: 3, Column: 1},
+		Message:  "target build",
+	}, diags[1])
+	assert.True(t, HasErrors(diags))
+
+	diags, err = run(repo, Config{Rules: map[string]RuleConfig{
+		"earthfile-parse-error": {Enabled: lo.ToPtr(false)},
+		"fake":                  {Severity: lo.ToPtr(SeverityError)},
+	}}, rules)
+	require.NoError(t, err)
+	require.Len(t, diags, 1)
+	assert.Equal(t, SeverityError, diags[0].Severity)
+
+	_, err = run(repo, Config{Rules: map[string]RuleConfig{"nonexistent": {}}}, rules)
+	assert.ErrorContains(t, err, `unknown rule "nonexistent"`)
+}
+
+func TestApplyFixes(t *testing.T) {
+	repo, err := LoadRepo("testdata/copypath", testLog)
+	require.NoError(t, err)
+	diags, err := run(repo, Config{}, []Rule{copyNonexistentPath{}})
+	require.NoError(t, err)
+
+	files, fixed, err := repo.ApplyFixes(diags)
+	require.NoError(t, err)
+	assert.Equal(t, 2, fixed)
+	require.Len(t, files, 1)
+	assert.Equal(t, `--- a/Earthfile
++++ b/Earthfile
+@@ -3,8 +3,7 @@
+ 
+ build:
+     COPY go.mod go.sum ./
+-    COPY $DIR/*.go missing.txt ./
+-    COPY --dir $DIR/*.rs ./
++    COPY $DIR/*.go ./
+     COPY --if-exists optional.txt ./
+     COPY $UNKNOWN_ARG/x ./
+     COPY +gen/out.txt ./
+`, UnifiedDiff(files[0]))
+}
+
+func TestWriteFixes(t *testing.T) {
+	repo := &Repo{Root: t.TempDir()}
+	p := filepath.Join(repo.Root, "Earthfile")
+	require.NoError(t, os.WriteFile(p, []byte("VERSION 0.6\n"), 0o600))
+
+	require.NoError(t, repo.WriteFixes([]FixedFile{{Path: "Earthfile", After: []string{"VERSION 0.7", ""}}}))
+	b, err := os.ReadFile(p)
+	require.NoError(t, err)
+	assert.Equal(t, "VERSION 0.7\n", string(b))
+	fi, err := os.Stat(p)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+}
+
+func TestApplyEdits(t *testing.T) {
+	lines := []string{"a", "b", "c", "d"}
+	assert.Equal(t, []string{"x", "a", "c", "y", "y2", "d"}, applyEdits(lines, []TextEdit{
+		{Line: 4, Insert: []string{"y", "y2"}},
+		{Line: 1, Insert: []string{"x"}},
+		{Line: 2, Delete: 1},
+		{Line: 4, Insert: []string{"y", "y2"}},
+	}))
+	assert.Equal(t, []string{"a", "B", "d", "e"}, applyEdits(lines, []TextEdit{
+		{Line: 2, Delete: 2, Insert: []string{"B"}},
+		{Line: 5, Insert: []string{"e"}},
+	}))
+}
+
+func TestRuleConfigDecodeOptions(t *testing.T) {
+	var rc RuleConfig
+	require.NoError(t, yaml.Unmarshal([]byte("options: {allow: [golang]}"), &rc))
+
+	var opts unpinnedBaseImageOptions
+	require.NoError(t, rc.decodeOptions(&opts))
+	assert.Equal(t, []string{"golang"}, opts.Allow)
+
+	require.NoError(t, yaml.Unmarshal([]byte("options: {alow: [golang]}"), &rc))
+	assert.ErrorContains(t, rc.decodeOptions(&opts), "field alow not found")
+}
+
+func TestWriteDiagnostics(t *testing.T) {
+	diags := []Diagnostic{
+		{Rule: "copy-nonexistent-path", Severity: SeverityError, Pos: Position{"a/Earthfile", 3, 5}, Message: "broken"},
+		{Rule: "fake", Severity: SeverityInfo, Pos: Position{File: "b/Earthfile"}, Message: "whole file"},
+	}
+
+	b := new(strings.Builder)
+	require.NoError(t, WriteDiagnostics(b, FormatCompact, diags))
+	assert.Equal(t, "a/Earthfile:3:5: copy-nonexistent-path: broken\nb/Earthfile: fake: whole file\n", b.String())
+
+	b.Reset()
+	require.NoError(t, WriteDiagnostics(b, FormatJSON, diags[:1]))
+	assert.JSONEq(t, `[{
+		"rule": "copy-nonexistent-path",
+		"severity": "error",
+		"position": {"file": "a/Earthfile", "line": 3, "column": 5},
+		"message": "broken"
+	}]`, b.String())
+
+	b.Reset()
+	require.NoError(t, WriteDiagnostics(b, FormatSARIF, diags))
+	var log sarifLog
+	require.NoError(t, json.Unmarshal([]byte(b.String()), &log))
+	require.Len(t, log.Runs, 1)
+	results := log.Runs[0].Results
+	require.Len(t, results, 2)
+	assert.Equal(t, "error", results[0].Level)
+	assert.Equal(t, &sarifRegion{StartLine: 3, StartColumn: 5}, results[0].Locations[0].PhysicalLocation.Region)
+	assert.Equal(t, "copy-nonexistent-path", log.Runs[0].Tool.Driver.Rules[*results[0].RuleIndex].ID)
+	assert.Equal(t, "note", results[1].Level)
+	assert.Nil(t, results[1].Locations[0].PhysicalLocation.Region)
+	assert.Nil(t, results[1].RuleIndex)
+}
+
+func TestSuppressions(t *testing.T) {
+	repo, err := LoadRepo("testdata/suppress", testLog)
+	require.NoError(t, err)
+	diags, err := run(repo, Config{}, []Rule{copyNonexistentPath{}, undefinedArg{}, unusedArg{}, unusedSuppression{}})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`Earthfile:12:5: COPY source "still-missing.txt" matches no file or directory (copy-nonexistent-path)`,
+		"Earthfile:13:1: suppression comment of copy-nonexistent-path suppresses no lint issue (unused-suppression)",
+		`Earthfile:15:1: suppression comment refers to unknown rule "no-such-rule" (unused-suppression)`,
+		"Earthfile:16:1: suppression comment lacks a rule name (unused-suppression)",
+		"Earthfile:20:1: suppression comment of copy-nonexistent-path precedes no command (unused-suppression)",
+	}, lo.Map(diags, func(d Diagnostic, _ int) string { return fmt.Sprintf("%s: %s (%s)", d.Pos, d.Message, d.Rule) }))
+}
@@ -0,0 +1,75 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is an output format of diagnostics.
+type Format string
+
+const (
+	FormatText    Format = "text"    // `pos: severity: message (rule)`, for humans
+	FormatCompact Format = "compact" // `file:line:col: rule: message`, for editors; unknown lines and columns are omitted
+	FormatJSON    Format = "json"    // An array of Diagnostic objects, for scripts
+	FormatSARIF   Format = "sarif"   // SARIF 2.1.0, for code scanning tools
+)
+
+var (
+	formats = []Format{FormatText, FormatCompact, FormatJSON, FormatSARIF}
+)
+
+func ParseFormat(s string) (Format, error) {
+	for _, f := range formats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("lint: unknown output format %q; expected one of %s", s, FormatNames())
+}
+
+// FormatNames returns the names of the supported output formats, for usage texts.
+func FormatNames() string {
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = string(f)
+	}
+	return strings.Join(names, ", ")
+}
+
+// WriteDiagnostics writes diagnostics to w in the given format.
+func WriteDiagnostics(w io.Writer, format Format, diags []Diagnostic) error {
+	switch format {
+	case FormatText:
+		for _, d := range diags {
+			if _, err := fmt.Fprintf(w, "%s: %s: %s (%s)\n", d.Pos, d.Severity, d.Message, d.Rule); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatCompact:
+		for _, d := range diags {
+			if _, err := fmt.Fprintf(w, "%s: %s: %s\n", d.Pos, d.Rule, d.Message); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatJSON:
+		if diags == nil {
+			diags = []Diagnostic{}
+		}
+		return writeJSON(w, diags)
+	case FormatSARIF:
+		return writeJSON(w, newSARIFLog(Rules(), diags))
+	default:
+		return fmt.Errorf("lint: unknown output format %q", format)
+	}
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,27 @@
+package lint
+
+import (
+	"sort"
+
+	"golang.org/x/exp/maps"
+)
+
+func init() {
+	Register(earthfileParseError{})
+}
+
+// earthfileParseError reports Earthfiles that could not be parsed, and were thus skipped by all other rules.
+type earthfileParseError struct{}
+
+func (earthfileParseError) Name() string              { return "earthfile-parse-error" }
+func (earthfileParseError) Doc() string               { return "Earthfile that cannot be parsed" }
+func (earthfileParseError) DefaultSeverity() Severity { return SeverityError }
+
+func (earthfileParseError) Check(p *Pass) error {
+	paths := maps.Keys(p.Repo.ParseErrors)
+	sort.Strings(paths)
+	for _, path := range paths {
+		p.Reportf(Position{File: path}, "%v", p.Repo.ParseErrors[path])
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+
+	"golang.org/x/exp/maps"
+)
+
+var (
+	registry = map[string]Rule{}
+)
+
+// Register makes a rule available to Run. It panics if a rule with the same name is already registered.
+func Register(r Rule) {
+	if _, ok := registry[r.Name()]; ok {
+		panic(fmt.Sprintf("lint: rule %s registered twice", r.Name()))
+	}
+	registry[r.Name()] = r
+}
+
+// Rules returns all registered rules, sorted by name.
+func Rules() []Rule {
+	res := maps.Values(registry)
+	sort.Slice(res, func(i, j int) bool { return res[i].Name() < res[j].Name() })
+	return res
+}
+
+// Lookup returns the registered rule with the given name, or nil.
+func Lookup(name string) Rule {
+	return registry[name]
+}
@@ -0,0 +1,123 @@
+package lint
+
+import (
+	"fmt"
+	"go/token"
+	"path/filepath"
+
+	"github.com/earthly/earthly/ast/spec"
+	"github.com/earthly/earthly/conslogging"
+	"github.com/samber/lo"
+
+	"github.com/dorfire/heavenly/pkg/earthfile"
+	"github.com/dorfire/heavenly/pkg/goparse"
+)
+
+// Repo is the set of files a lint run inspects.
+type Repo struct {
+	Root        string                 // Absolute path of the repo root
+	Earthfiles  []*earthfile.Earthfile // Successfully parsed Earthfiles in the repo
+	ParseErrors map[string]error       // Repo-relative Earthfile path -> parse error
+	Log         conslogging.ConsoleLogger
+
+	sources  map[string][]string // Lazily loaded by Source
+	goPkgs   []goparse.Package   // Lazily loaded by GoPackages
+	goCopies []goTargetCopies    // Lazily loaded by goTargetCopies
+}
+
+// LoadRepo discovers and parses all Earthfiles under the given root dir.
+func LoadRepo(root string, log conslogging.ConsoleLogger) (*Repo, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := earthfile.Discover(root)
+	if err != nil {
+		return nil, fmt.Errorf("lint: could not discover Earthfiles in %s: %w", root, err)
+	}
+
+	res := &Repo{Root: root, ParseErrors: map[string]error{}, Log: log, sources: map[string][]string{}}
+	for _, p := range paths {
+		ef, err := earthfile.Parse(p)
+		if err != nil {
+			res.ParseErrors[res.RelPath(p)] = err
+			continue
+		}
+		res.Earthfiles = append(res.Earthfiles, ef)
+	}
+	log.DebugPrintf("Loaded %d Earthfiles from %s", len(res.Earthfiles), root)
+
+	return res, nil
+}
+
+// RelPath returns the slash-separated path of p relative to the repo root.
+// If p is not in the repo, it is returned as is.
+func (r *Repo) RelPath(p string) string {
+	rel, err := filepath.Rel(r.Root, p)
+	if err != nil {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Earthfile returns the successfully parsed Earthfile in the given dir, or nil if there is none.
+func (r *Repo) Earthfile(dir string) *earthfile.Earthfile {
+	dir = filepath.Clean(dir)
+	for _, ef := range r.Earthfiles {
+		if ef.Dir == dir {
+			return ef
+		}
+	}
+	return nil
+}
+
+// Pos returns the Position of a source location in the given Earthfile.
+func (r *Repo) Pos(ef *earthfile.Earthfile, loc *spec.SourceLocation) Position {
+	pos := Position{File: r.RelPath(ef.Path)}
+	if loc != nil {
+		// antlr columns are 0-based
+		pos.Line, pos.Column = loc.StartLine, loc.StartColumn+1
+	}
+	return pos
+}
+
+// GoPackages returns the packages of all Go modules in the repo.
+func (r *Repo) GoPackages() ([]goparse.Package, error) {
+	if r.goPkgs != nil {
+		return r.goPkgs, nil
+	}
+
+	mods, err := goparse.FindModules(r.Root)
+	if err != nil {
+		return nil, fmt.Errorf("lint: could not find Go modules in %s: %w", r.Root, err)
+	}
+
+	r.goPkgs = []goparse.Package{}
+	for _, m := range mods {
+		pkgs, err := goparse.ModulePackages(m)
+		if err != nil {
+			return nil, fmt.Errorf("lint: could not parse Go module %s: %w", r.RelPath(m), err)
+		}
+		for _, pkg := range pkgs {
+			// Unparsable files are left to the Go toolchain to report
+			pkg.Files = lo.Filter(pkg.Files, func(f goparse.File, _ int) bool {
+				if f.Err != nil {
+					r.Log.DebugPrintf("Skipping unparsable Go file %s: %v", r.RelPath(f.Path), f.Err)
+				}
+				return f.Err == nil
+			})
+			if len(pkg.Files) > 0 {
+				r.goPkgs = append(r.goPkgs, pkg)
+			}
+		}
+	}
+	r.Log.DebugPrintf("Loaded %d Go packages from %d modules", len(r.goPkgs), len(mods))
+
+	return r.goPkgs, nil
+}
+
+// GoPos returns the Position of a Go source position.
+func (r *Repo) GoPos(pos token.Position) Position {
+	return Position{File: r.RelPath(pos.Filename), Line: pos.Line, Column: pos.Column}
+}
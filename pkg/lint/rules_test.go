@@ -0,0 +1,145 @@
+package lint
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// checkRule runs a single rule over a testdata repo and returns its diagnostics as "pos: message" strings.
+func checkRule(t *testing.T, r Rule, dir string) []string {
+	t.Helper()
+	return checkRuleWithOptions(t, r, dir, "")
+}
+
+// checkRuleWithOptions is like checkRule, with the given YAML rule options.
+func checkRuleWithOptions(t *testing.T, r Rule, dir string, options string) []string {
+	t.Helper()
+	repo, err := LoadRepo(dir, testLog)
+	require.NoError(t, err)
+
+	var rc RuleConfig
+	require.NoError(t, yaml.Unmarshal([]byte(options), &rc.Options))
+	diags, err := run(repo, Config{Rules: map[string]RuleConfig{r.Name(): rc}}, []Rule{r})
+	require.NoError(t, err)
+
+	res := make([]string, 0, len(diags))
+	for _, d := range diags {
+		res = append(res, fmt.Sprintf("%s: %s", d.Pos, d.Message))
+	}
+	return res
+}
+
+func TestCopyNonexistentPath(t *testing.T) {
+	assert.Equal(t, []string{
+		`Earthfile:6:5: COPY source "missing.txt" matches no file or directory`,
+		`Earthfile:7:5: COPY source "lib/*.rs" matches no file or directory`,
+	}, checkRule(t, copyNonexistentPath{}, "testdata/copypath"))
+}
+
+func TestGoMainImport(t *testing.T) {
+	assert.Equal(t, []string{
+		"lib/lib.go:6:2: example.com/gomain/lib imports example.com/gomain/cmd/tool, which is a `main` package",
+		"lib/lib_test.go:6:2: example.com/gomain/lib imports example.com/gomain/cmd/tool, which is a `main` package",
+	}, checkRule(t, goMainImport{}, "testdata/gomain"))
+}
+
+func TestGoImportWithoutCopy(t *testing.T) {
+	// The root +build target is checked against the imports of all packages below it, which its `COPY . .` covers
+	assert.Equal(t, []string{
+		"svc/Earthfile:8:1: target +build lacks `COPY --dir $TOP/libs/a/+src/* $TOP/libs/a/`, which its Go imports need",
+		"svc/Earthfile:13:1: target +test lacks `COPY --dir $TOP/libs/a/+src/* $TOP/libs/a/`, which its Go imports need",
+		// +integration inherits the COPY commands of +test, although its FROM command has flags
+		"svc/Earthfile:18:1: target +integration lacks `COPY --dir $TOP/libs/a/+src/* $TOP/libs/a/`, " +
+			"which its Go imports need",
+	}, checkRule(t, goImportWithoutCopy{}, "testdata/gocopy"))
+
+	assert.Equal(t, []string{
+		"svc/Earthfile:15:5: target +test copies Go package sources its Go imports don't need",
+	}, checkRule(t, goCopyWithoutImport{}, "testdata/gocopy"))
+}
+
+func TestGoPackageWithoutSrc(t *testing.T) {
+	assert.Equal(t, []string{
+		"svc/svc.go:1:1: Go package dir svc is not covered by a +src target: svc/Earthfile has no +src target",
+		"tools/gen/gen.go:3:1: Go package dir tools/gen is not covered by a +src target: " +
+			"the +src target in tools/Earthfile does not COPY it",
+	}, checkRule(t, goPackageWithoutSrc{}, "testdata/gosrc"))
+}
+
+func TestDartImportWithoutCopy(t *testing.T) {
+	assert.Equal(t, []string{
+		"apps/app/Earthfile:4:1: target +build lacks `COPY --dir $TOP/packages/core/+src/* ../../packages/core/`, " +
+			"which its Dart dependencies need",
+		"apps/app/Earthfile:4:1: target +build lacks `COPY --dir $TOP/packages/testing/+src/* ../../packages/testing/`, " +
+			"which its Dart dependencies need",
+	}, checkRule(t, dartImportWithoutCopy{}, "../dartdepresolver/testdata"))
+}
+
+func TestDuplicateCopy(t *testing.T) {
+	assert.Equal(t, []string{
+		"Earthfile:6:5: `COPY a.txt ./` duplicates a preceding COPY command",
+		"Earthfile:12:9: `COPY b.txt ./` duplicates a preceding COPY command",
+	}, checkRule(t, duplicateCopy{}, "testdata/dupcopy"))
+}
+
+func TestArgs(t *testing.T) {
+	assert.Equal(t, []string{
+		"Earthfile:12:5: `$DEST` refers to no ARG of target +build nor a global ARG",
+	}, checkRule(t, undefinedArg{}, "testdata/args"))
+
+	assert.Equal(t, []string{
+		"Earthfile:3:1: global ARG UNUSED_GLOBAL is never referenced in the Earthfile",
+		"Earthfile:9:5: ARG UNUSED is never referenced in target +build",
+	}, checkRule(t, unusedArg{}, "testdata/args"))
+
+	assert.Equal(t, []string{
+		"Earthfile:24:5: BUILD +build does not pass ARG VERSION, which it requires",
+		"Earthfile:25:5: BUILD ./lib+lib does not pass ARG NAME, which it requires",
+		"Earthfile:27:5: COPY ./lib+lib does not pass ARG NAME, which it requires",
+	}, checkRule(t, missingRequiredArg{}, "testdata/args"))
+}
+
+func TestUnpinnedBaseImage(t *testing.T) {
+	assert.Equal(t, []string{
+		"Earthfile:3:1: base image golang:1.20 is referenced by mutable tag :1.20 instead of by @sha256: digest",
+		"Earthfile:7:5: base image alpine is referenced by mutable tag :latest instead of by @sha256: digest",
+		"Earthfile:10:5: base image registry.local:5000/acme/tools:v1 is referenced by mutable tag :v1 " +
+			"instead of by @sha256: digest",
+		"Earthfile:13:5: base image golang:1.20-alpine is referenced by mutable tag :1.20-alpine " +
+			"instead of by @sha256: digest",
+	}, checkRule(t, unpinnedBaseImage{}, "testdata/baseimage"))
+
+	assert.Equal(t, []string{
+		"Earthfile:13:5: base image golang:1.20-alpine is referenced by mutable tag :1.20-alpine " +
+			"instead of by @sha256: digest",
+	}, checkRuleWithOptions(t, unpinnedBaseImage{}, "testdata/baseimage",
+		`allow: ["alpine:latest", "registry.local:5000/acme/*", "golang:1.2?"]`))
+}
+
+func TestDanglingTargetRef(t *testing.T) {
+	assert.Equal(t, []string{
+		"Earthfile:7:5: BUILD references nonexistent target ./lib+tset: " +
+			"earthfile: local target 'tset' not found. available targets: build, test",
+		"Earthfile:8:5: BUILD references target ./nope+build, but there's no Earthfile in nope",
+		"Earthfile:10:5: COPY references nonexistent target +gen: " +
+			"earthfile: local target 'gen' not found. available targets: build, all, deps",
+		"Earthfile:14:5: FROM references nonexistent target +dep: " +
+			"earthfile: local target 'dep' not found. available targets: build, all, deps",
+		"lib/Earthfile:8:5: FROM references nonexistent target ../+dpes: " +
+			"earthfile: local target 'dpes' not found. available targets: build, all, deps",
+	}, checkRule(t, danglingTargetRef{}, "testdata/targetref"))
+}
+
+func TestCacheHostileLayerOrder(t *testing.T) {
+	assert.Equal(t, []string{
+		"svc/Earthfile:10:5: `COPY . .` precedes `RUN go mod download`, " +
+			"so any source change invalidates the cached dependency download",
+		"svc/Earthfile:15:5: no COPY of only go.mod and go.sum precedes `COPY --dir +src/* .`, " +
+			"so Go modules are downloaded again whenever a source changes",
+		"web/Earthfile:5:5: `COPY . .` precedes `RUN npm ci`, so any source change invalidates the cached dependency download",
+	}, checkRule(t, cacheHostileLayerOrder{}, "testdata/layerorder"))
+}
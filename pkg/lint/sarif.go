@@ -0,0 +1,124 @@
+package lint
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	toolName     = "heavenly"
+	toolURI      = "https://github.com/dorfire/heavenly"
+)
+
+// The subset of the SARIF 2.1.0 object model which lint results map to.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex *int            `json:"ruleIndex,omitempty"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+func newSARIFLog(rules []Rule, diags []Diagnostic) sarifLog {
+	driver := sarifDriver{Name: toolName, InformationURI: toolURI, Rules: make([]sarifRule, len(rules))}
+	ruleIndices := map[string]int{}
+	for i, r := range rules {
+		ruleIndices[r.Name()] = i
+		driver.Rules[i] = sarifRule{
+			ID:                   r.Name(),
+			ShortDescription:     sarifMessage{r.Doc()},
+			DefaultConfiguration: sarifConfiguration{sarifLevel(r.DefaultSeverity())},
+		}
+	}
+
+	results := make([]sarifResult, len(diags))
+	for i, d := range diags {
+		loc := sarifPhysicalLocation{
+			// Paths are relative to the repo root, which code scanning tools know as the source root
+			ArtifactLocation: sarifArtifactLocation{URI: d.Pos.File, URIBaseID: "%SRCROOT%"},
+		}
+		if d.Pos.Line > 0 {
+			loc.Region = &sarifRegion{StartLine: d.Pos.Line, StartColumn: d.Pos.Column}
+		}
+
+		results[i] = sarifResult{
+			RuleID:    d.Rule,
+			Level:     sarifLevel(d.Severity),
+			Message:   sarifMessage{d.Message},
+			Locations: []sarifLocation{{loc}},
+		}
+		if idx, ok := ruleIndices[d.Rule]; ok {
+			results[i].RuleIndex = &idx
+		}
+	}
+
+	return sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{{Tool: sarifTool{driver}, Results: results}},
+	}
+}
+
+func sarifLevel(s Severity) string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "note"
+	}
+}
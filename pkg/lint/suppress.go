@@ -0,0 +1,129 @@
+package lint
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"golang.org/x/exp/maps"
+)
+
+var (
+	// E.g. `# heavenly:ignore copy-nonexistent-path generated by +gen` or `# heavenly:ignore-file unused-arg`
+	suppressionRe    = regexp.MustCompile(`^\s*#\s*heavenly:(ignore-file|ignore)\b\s*(\S*)\s*(.*)$`)
+	blankOrCommentRe = regexp.MustCompile(`^\s*(#.*)?$`)
+)
+
+func init() {
+	Register(unusedSuppression{})
+}
+
+// unusedSuppression reports suppression comments which suppress no diagnostic. Its diagnostics are reported by run,
+// once all other rules have.
+type unusedSuppression struct{}
+
+func (unusedSuppression) Name() string { return "unused-suppression" }
+func (unusedSuppression) Doc() string {
+	return "`# heavenly:ignore` comment which suppresses no lint issue"
+}
+func (unusedSuppression) DefaultSeverity() Severity { return SeverityWarning }
+func (unusedSuppression) Check(*Pass) error         { return nil }
+
+// suppression is a `# heavenly:ignore <rule> [reason]` comment, which suppresses the diagnostics of a rule on the
+// line following it and any other suppression comments; or a `# heavenly:ignore-file <rule> [reason]` comment, which
+// suppresses them in the whole file.
+type suppression struct {
+	pos    Position // Position of the comment
+	rule   string
+	reason string
+	line   int // The suppressed line, or 0 for the whole file
+	used   bool
+}
+
+func (s *suppression) suppresses(d Diagnostic) bool {
+	return d.Rule == s.rule && d.Pos.File == s.pos.File && (s.line == 0 || d.Pos.Line == s.line)
+}
+
+// suppressions parses the suppression comments in all Earthfiles in the repo, including ones that could not be parsed.
+func (r *Repo) suppressions() ([]*suppression, error) {
+	paths := maps.Keys(r.ParseErrors)
+	for _, ef := range r.Earthfiles {
+		paths = append(paths, r.RelPath(ef.Path))
+	}
+	sort.Strings(paths)
+
+	var res []*suppression
+	for _, p := range paths {
+		lines, err := r.Source(filepath.Join(r.Root, filepath.FromSlash(p)))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, parseSuppressions(p, lines)...)
+	}
+	return res, nil
+}
+
+func parseSuppressions(path string, lines []string) []*suppression {
+	var res []*suppression
+	for i, l := range lines {
+		m := suppressionRe.FindStringSubmatch(l)
+		if m == nil {
+			continue
+		}
+
+		s := &suppression{pos: Position{File: path, Line: i + 1, Column: 1}, rule: m[2], reason: m[3]}
+		if m[1] == "ignore" {
+			// The suppressed line is the first that isn't blank or a comment
+			s.line = -1
+			for j := i + 1; j < len(lines); j++ {
+				if !blankOrCommentRe.MatchString(lines[j]) {
+					s.line = j + 1
+					break
+				}
+			}
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+// applySuppressions drops the diagnostics which suppression comments in the repo suppress. If the unused-suppression
+// rule ran, the returned diagnostics include ones for suppressions of rules that ran but suppressed nothing.
+func (r *Repo) applySuppressions(diags []Diagnostic, passes map[string]*Pass) ([]Diagnostic, error) {
+	sups, err := r.suppressions()
+	if err != nil {
+		return nil, err
+	}
+
+	res := diags[:0]
+	for _, d := range diags {
+		suppressed := false
+		for _, s := range sups {
+			if s.suppresses(d) {
+				s.used, suppressed = true, true
+			}
+		}
+		if !suppressed {
+			res = append(res, d)
+		}
+	}
+
+	unusedPass, ok := passes[unusedSuppression{}.Name()]
+	if !ok {
+		return res, nil
+	}
+	for _, s := range sups {
+		_, ran := passes[s.rule]
+		switch {
+		case s.rule == "":
+			unusedPass.Reportf(s.pos, "suppression comment lacks a rule name")
+		case !ran && Lookup(s.rule) == nil:
+			unusedPass.Reportf(s.pos, "suppression comment refers to unknown rule %q", s.rule)
+		case s.line == -1:
+			unusedPass.Reportf(s.pos, "suppression comment of %s precedes no command", s.rule)
+		case ran && !s.used:
+			unusedPass.Reportf(s.pos, "suppression comment of %s suppresses no lint issue", s.rule)
+		}
+	}
+	return append(res, unusedPass.diags...), nil
+}
@@ -0,0 +1,88 @@
+package lint
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/earthly/earthly/ast/spec"
+
+	"github.com/dorfire/heavenly/pkg/earthfile"
+)
+
+func init() {
+	Register(danglingTargetRef{})
+}
+
+// danglingTargetRef reports BUILD, FROM and COPY commands which reference targets, or Earthfiles, that don't exist in
+// the repo.
+type danglingTargetRef struct{}
+
+func (danglingTargetRef) Name() string { return "dangling-target-ref" }
+func (danglingTargetRef) Doc() string {
+	return "BUILD, FROM or COPY command referencing a nonexistent target"
+}
+func (danglingTargetRef) DefaultSeverity() Severity { return SeverityError }
+
+func (r danglingTargetRef) Check(p *Pass) error {
+	for _, ef := range p.Repo.Earthfiles {
+		check := func(recipe spec.Block) {
+			for _, c := range commands(recipe) {
+				for _, ref := range targetRefs(c) {
+					r.checkRef(p, ef, c, ref)
+				}
+			}
+		}
+
+		recipes(ef, func(_ *spec.Target, recipe spec.Block) { check(recipe) })
+		for _, cmd := range ef.Spec.UserCommands {
+			check(cmd.Recipe)
+		}
+	}
+	return nil
+}
+
+func (danglingTargetRef) checkRef(p *Pass, ef *earthfile.Earthfile, c spec.Command, ref string) {
+	expanded := ef.ExpandArgs(ref)
+	if !earthfile.IsLocalTargetRef(expanded) || strings.ContainsRune(expanded, '$') {
+		return // Remote or imported target, or one that depends on an ARG that can't be resolved statically
+	}
+
+	pos := p.Repo.Pos(ef, c.SourceLocation)
+	dir, name, _ := strings.Cut(expanded, "+")
+	targetEf := p.Repo.Earthfile(filepath.Join(ef.Dir, dir))
+	switch {
+	case targetEf != nil:
+		if _, _, err := targetEf.Target("+" + name); err != nil {
+			p.Reportf(pos, "%s references nonexistent target %s: %v", c.Name, ref, err)
+		}
+	case p.Repo.ParseErrors[p.Repo.RelPath(filepath.Join(ef.Dir, dir, "Earthfile"))] != nil:
+		// Reported by earthfile-parse-error
+	default:
+		p.Reportf(pos, "%s references target %s, but there's no Earthfile in %s", c.Name, ref,
+			p.Repo.RelPath(filepath.Join(ef.Dir, dir)))
+	}
+}
+
+// targetRefs returns the target references in a command, e.g. `./dir+target` in `BUILD ./dir+target`, and `+target`
+// in `COPY +target/artifact .`.
+func targetRefs(c spec.Command) []string {
+	switch c.Name {
+	case "BUILD", "FROM":
+		call, err := earthfile.ParseTargetCall(c.Args)
+		if err != nil || !strings.ContainsRune(call.Target, '+') {
+			return nil
+		}
+		return []string{call.Target}
+	case "COPY":
+		var res []string
+		for _, src := range copySources(c) {
+			if src = earthfile.UnwrapArtifactRef(src); strings.ContainsRune(src, '+') {
+				target, _ := earthfile.SplitArtifactRef(src)
+				res = append(res, target)
+			}
+		}
+		return res
+	default:
+		return nil
+	}
+}
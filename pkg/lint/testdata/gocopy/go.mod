@@ -0,0 +1,3 @@
+module example.com/gocopy
+
+go 1.19
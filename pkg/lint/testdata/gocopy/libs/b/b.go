@@ -0,0 +1,3 @@
+package b
+
+const X = 1
@@ -0,0 +1,3 @@
+package c
+
+const X = 1
@@ -0,0 +1,5 @@
+package main
+
+import "example.com/gocopy/libs/a"
+
+func main() { _ = a.X }
@@ -0,0 +1,9 @@
+package main
+
+import (
+	"testing"
+
+	"example.com/gocopy/libs/b"
+)
+
+func TestMain(t *testing.T) { _ = b.X }
@@ -0,0 +1,9 @@
+package main_test
+
+import (
+	"testing"
+
+	"example.com/gomain/cmd/tool"
+)
+
+func TestMain(t *testing.T) { _ = tool.X }
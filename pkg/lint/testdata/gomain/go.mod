@@ -0,0 +1,3 @@
+module example.com/gomain
+
+go 1.19
@@ -0,0 +1,4 @@
+package lib
+
+import (
+	"fmt
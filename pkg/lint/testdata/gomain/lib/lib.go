@@ -0,0 +1,9 @@
+package lib
+
+import (
+	"fmt"
+
+	"example.com/gomain/cmd/tool"
+)
+
+var _ = fmt.Sprint(tool.X)
@@ -0,0 +1,10 @@
+package lib_test
+
+import (
+	"testing"
+
+	_ "example.com/gomain/cmd/tool"
+	_ "example.com/gomain/lib"
+)
+
+func TestLib(t *testing.T) {}
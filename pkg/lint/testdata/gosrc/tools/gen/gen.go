@@ -0,0 +1,3 @@
+// Code generation.
+
+package gen
@@ -0,0 +1,2 @@
+// Package tools holds tools.
+package tools
@@ -0,0 +1,3 @@
+module example.com/layerorder
+
+go 1.20
@@ -0,0 +1,54 @@
+package lint
+
+import (
+	"github.com/earthly/earthly/ast/spec"
+
+	"github.com/dorfire/heavenly/pkg/earthfile"
+)
+
+type cmdCollector struct {
+	earthfile.UnimplementedStmtVisitor
+	cmds []spec.Command
+}
+
+func (v *cmdCollector) VisitCommand(c spec.Command) {
+	v.cmds = append(v.cmds, c)
+}
+
+// commands returns the commands in the given recipe, including nested ones, in source order.
+func commands(recipe spec.Block) []spec.Command {
+	v := &cmdCollector{}
+	earthfile.WalkRecipe(recipe, v)
+	return v.cmds
+}
+
+// recipes calls fn for the base recipe of the given Earthfile, with a nil target, and then for each of its targets.
+func recipes(ef *earthfile.Earthfile, fn func(t *spec.Target, recipe spec.Block)) {
+	fn(nil, ef.Spec.BaseRecipe)
+	for i := range ef.Spec.Targets {
+		fn(&ef.Spec.Targets[i], ef.Spec.Targets[i].Recipe)
+	}
+}
+
+// blocks calls fn for the given recipe and for each block nested in it, like the body of an IF command.
+func blocks(recipe spec.Block, fn func(spec.Block)) {
+	fn(recipe)
+	for _, s := range recipe {
+		switch {
+		case s.With != nil:
+			blocks(s.With.Body, fn)
+		case s.If != nil:
+			blocks(s.If.IfBody, fn)
+			for _, b := range s.If.ElseIf {
+				blocks(b.Body, fn)
+			}
+			if s.If.ElseBody != nil {
+				blocks(*s.If.ElseBody, fn)
+			}
+		case s.For != nil:
+			blocks(s.For.Body, fn)
+		case s.Wait != nil:
+			blocks(s.Wait.Body, fn)
+		}
+	}
+}
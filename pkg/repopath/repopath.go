@@ -0,0 +1,98 @@
+// Package repopath canonicalizes the paths of files in a git repo, so that paths which git reports can be compared to
+// ones found by analyzing Earthfiles, regardless of the current directory.
+package repopath
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var ErrOutsideRepo = errors.New("path is outside of the repo")
+
+// Path is the clean, slash-separated path of a file relative to the root of its repo, as git reports it; e.g.
+// `pkg/lint/lint.go`. The root itself is ".".
+type Path string
+
+// FromSlash returns the Path of a slash-separated path relative to the repo root, such as one git reports.
+func FromSlash(p string) Path {
+	return Path(path.Clean(p))
+}
+
+// Strings returns the given paths as strings, sorted.
+func Strings(paths []Path) []string {
+	res := make([]string, len(paths))
+	for i, p := range paths {
+		res[i] = string(p)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Root is the absolute path of the root directory of a repo, which resolves file system paths to Paths.
+type Root struct {
+	dir      string // As the current directory leads to it, possibly through symlinks
+	realPath string // With symlinks evaluated
+}
+
+func NewRoot(dir string) (Root, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return Root{}, fmt.Errorf("repopath: %w", err)
+	}
+	realPath, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return Root{}, fmt.Errorf("repopath: %w", err)
+	}
+	return Root{abs, realPath}, nil
+}
+
+// Dir returns the absolute path of the repo root.
+func (r Root) Dir() string { return r.dir }
+
+// Rel returns the Path of the given file system path, which is either absolute or relative to the current directory.
+// The file need not exist.
+func (r Root) Rel(p string) (Path, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", fmt.Errorf("repopath: %w", err)
+	}
+	if res, ok := relPath(r.dir, abs); ok {
+		return res, nil
+	}
+
+	// p may lead to the repo through a symlink. The file itself may be a symlink in the repo, so only its dir is
+	// evaluated.
+	if res, ok := relPath(r.realPath, filepath.Join(evalSymlinks(filepath.Dir(abs)), filepath.Base(abs))); ok {
+		return res, nil
+	}
+	return "", fmt.Errorf("repopath: %s: %w", p, ErrOutsideRepo)
+}
+
+// Abs returns the absolute file system path of the given Path.
+func (r Root) Abs(p Path) string {
+	return filepath.Join(r.dir, filepath.FromSlash(string(p)))
+}
+
+// evalSymlinks evaluates the symlinks in the given absolute path, up to the last dir in it that exists.
+func evalSymlinks(abs string) string {
+	for d := abs; ; d = filepath.Dir(d) {
+		if res, err := filepath.EvalSymlinks(d); err == nil {
+			return filepath.Join(res, strings.TrimPrefix(abs, d))
+		}
+		if d == filepath.Dir(d) {
+			return abs
+		}
+	}
+}
+
+func relPath(root, abs string) (Path, bool) {
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return Path(filepath.ToSlash(rel)), true
+}
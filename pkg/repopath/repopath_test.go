@@ -0,0 +1,51 @@
+package repopath
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRootRel(t *testing.T) {
+	tmp := t.TempDir()
+	repo := filepath.Join(tmp, "repo")
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, "sub", "dir"), 0o755))
+	require.NoError(t, os.Symlink(repo, filepath.Join(tmp, "link")))
+
+	root, err := NewRoot(repo)
+	require.NoError(t, err)
+
+	for in, want := range map[string]Path{
+		repo: ".",
+		filepath.Join(repo, "sub", "dir", "file.go"):  "sub/dir/file.go",
+		filepath.Join(repo, "sub", "..", "a.txt"):     "a.txt",
+		filepath.Join(tmp, "link", "sub", "x.txt"):    "sub/x.txt", // Through a symlink to the repo
+		filepath.Join(tmp, "link", "missing", "y.go"): "missing/y.go",
+	} {
+		got, err := root.Rel(in)
+		if assert.NoError(t, err, in) {
+			assert.Equal(t, want, got, in)
+		}
+	}
+
+	_, err = root.Rel(filepath.Join(tmp, "elsewhere.txt"))
+	assert.ErrorIs(t, err, ErrOutsideRepo)
+
+	// Relative paths are relative to the current directory, wherever it is in the repo
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(filepath.Join(repo, "sub")))
+	defer func() { _ = os.Chdir(wd) }()
+	got, err := root.Rel(filepath.Join("dir", "..", "..", "b.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, Path("b.txt"), got)
+	assert.Equal(t, filepath.Join(repo, "sub", "b.txt"), root.Abs("sub/b.txt"))
+}
+
+func TestFromSlash(t *testing.T) {
+	assert.Equal(t, Path("a/b.txt"), FromSlash("./a//b.txt"))
+	assert.Equal(t, Path("."), FromSlash(""))
+}